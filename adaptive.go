@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// adaptive timeout is p99 latency times multiplier plus slack, slack keeps it sane when latency is tiny
+const (
+	defaultAdaptiveTimeoutMultiplier = 2.0
+	defaultAdaptiveTimeoutSlack      = 100 * time.Millisecond
+)
+
+// LatencyStats gives recent latency of requests for WithAdaptiveTimeout
+type LatencyStats interface {
+	P99() time.Duration
+}
+
+// WithAdaptiveTimeout sets timeout of every attempt to two times P99 of stats plus 100ms instead of a fixed one,
+// so it follows how the server actually performs, see WithAdaptiveTimeoutTuning to change multiplier and slack.
+// Timeout of WithTimeout is used while stats have no data yet.
+// stats are not updated by CustomHTTPRequest, caller feeds them, see NewRollingLatencyStats
+func WithAdaptiveTimeout(stats LatencyStats) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.latencyStats = stats
+	}
+}
+
+// WithAdaptiveTimeoutTuning changes timeout of WithAdaptiveTimeout to P99 times multiplier plus slack,
+// zero multiplier or slack keeps its default
+func WithAdaptiveTimeoutTuning(multiplier float64, slack time.Duration) OptReqParamsOption {
+	if multiplier < 0 || slack < 0 {
+		return withOptErr(fmt.Errorf("%w: adaptive timeout multiplier %v and slack %v can't be negative",
+			ErrInvalidOption, multiplier, slack))
+	}
+	return func(s *OptReqParams) {
+		s.adaptiveMultiplier, s.adaptiveSlack = multiplier, slack
+	}
+}
+
+// effectiveTimeout returns timeout for http.Client, adaptive one if there is enough data for it
+func (p *OptReqParams) effectiveTimeout() time.Duration {
+	if p.latencyStats == nil {
+		return p.timeout
+	}
+	p99 := p.latencyStats.P99()
+	if p99 <= 0 {
+		return p.timeout
+	}
+	multiplier, slack := p.adaptiveMultiplier, p.adaptiveSlack
+	if multiplier == 0 {
+		multiplier = defaultAdaptiveTimeoutMultiplier
+	}
+	if slack == 0 {
+		slack = defaultAdaptiveTimeoutSlack
+	}
+	return time.Duration(float64(p99)*multiplier) + slack
+}
+
+// RollingLatencyStats keeps last latencies in a circular buffer, safe for concurrent use
+type RollingLatencyStats struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+// NewRollingLatencyStats returns stats over the last windowSize latencies, at least one
+func NewRollingLatencyStats(windowSize int) *RollingLatencyStats {
+	return &RollingLatencyStats{samples: make([]time.Duration, max(windowSize, 1))}
+}
+
+// Record adds latency of a finished request, pushing out the oldest one when window is full
+func (s *RollingLatencyStats) Record(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// P99 returns 99th percentile of latencies in the window, zero when nothing was recorded yet
+func (s *RollingLatencyStats) P99() time.Duration {
+	s.mu.Lock()
+	n := s.next
+	if s.full {
+		n = len(s.samples)
+	}
+	window := slices.Clone(s.samples[:n])
+	s.mu.Unlock()
+
+	if len(window) == 0 {
+		return 0
+	}
+	slices.Sort(window)
+	// nearest rank method
+	rank := (99*len(window) + 99) / 100
+	return window[rank-1]
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fixedP99 time.Duration
+
+func (f fixedP99) P99() time.Duration { return time.Duration(f) }
+
+func TestRollingLatencyStatsP99(t *testing.T) {
+	s := NewRollingLatencyStats(100)
+	if p := s.P99(); p != 0 {
+		t.Errorf("P99 of empty stats = %v", p)
+	}
+	for i := 1; i <= 150; i++ {
+		s.Record(time.Duration(i) * time.Millisecond)
+	}
+	// window keeps 51..150ms
+	if p := s.P99(); p != 149*time.Millisecond {
+		t.Errorf("P99 = %v, want 149ms", p)
+	}
+}
+
+func TestAdaptiveTimeoutValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		stats      LatencyStats
+		multiplier float64
+		slack      time.Duration
+		want       time.Duration
+	}{
+		{"defaults", fixedP99(time.Second), 0, 0, 2100 * time.Millisecond},
+		{"custom", fixedP99(time.Second), 1.5, 50 * time.Millisecond, 1550 * time.Millisecond},
+		{"custom multiplier only", fixedP99(time.Second), 3, 0, 3100 * time.Millisecond},
+		{"no data uses WithTimeout", fixedP99(0), 3, time.Second, 7 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewOptReqParams(WithTimeout(7*time.Second), WithAdaptiveTimeout(tt.stats),
+				WithAdaptiveTimeoutTuning(tt.multiplier, tt.slack))
+			if got := p.effectiveTimeout(); got != tt.want {
+				t.Errorf("effectiveTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveTimeoutNegative(t *testing.T) {
+	_, err := call(t, "http://127.0.0.1:1", WithAdaptiveTimeout(fixedP99(time.Second)), WithAdaptiveTimeoutTuning(-1, 0))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestAdaptiveTimeoutAppliesToRequest(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	start := time.Now()
+	_, err := call(t, srv.URL, WithTimeout(time.Minute),
+		WithAdaptiveTimeout(fixedP99(10*time.Millisecond)), WithAdaptiveTimeoutTuning(1, 10*time.Millisecond))
+	if err == nil {
+		t.Fatal("slow request didn't time out")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("request took %v, adaptive timeout is 20ms", d)
+	}
+}
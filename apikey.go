@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// default names used by API key options when none is given
+const (
+	DefaultAPIKeyHeader = "X-API-Key"
+	DefaultAPIKeyParam  = "api_key"
+)
+
+// WithAPIKeyInHeader authenticates with key sent in headerName instead of bearer token from MyLoginAPI.
+// empty headerName means DefaultAPIKeyHeader. It can't be combined with WithBasicAuth or WithBearerToken,
+// and of WithAPIKeyInHeader and WithAPIKeyInQuery the last one wins
+func WithAPIKeyInHeader(key, headerName string) OptReqParamsOption {
+	if headerName == "" {
+		headerName = DefaultAPIKeyHeader
+	}
+	return withKind("WithAPIKeyInHeader", withAPIKey(key, headerName, false))
+}
+
+// WithAPIKeyInQuery is same as WithAPIKeyInHeader but sends key as query param paramName,
+// empty paramName means DefaultAPIKeyParam
+func WithAPIKeyInQuery(key, paramName string) OptReqParamsOption {
+	if paramName == "" {
+		paramName = DefaultAPIKeyParam
+	}
+	return withKind("WithAPIKeyInQuery", withAPIKey(key, paramName, true))
+}
+
+// withAPIKey stores api key settings, empty key is returned by CustomHTTPRequest without making any call
+func withAPIKey(key, name string, inQuery bool) OptReqParamsOption {
+	if key == "" {
+		return withOptErr(fmt.Errorf("%w: empty api key", ErrInvalidOption))
+	}
+	return func(s *OptReqParams) {
+		s.apiKey, s.apiKeyName, s.apiKeyInQuery = key, name, inQuery
+	}
+}
+
+// applyAPIKey sets api key of p on req, in header or query
+func applyAPIKey(req *http.Request, p *OptReqParams) {
+	if !p.apiKeyInQuery {
+		req.Header.Set(p.apiKeyName, p.apiKey)
+		return
+	}
+	q := req.URL.Query()
+	q.Set(p.apiKeyName, p.apiKey)
+	req.URL.RawQuery = q.Encode()
+}
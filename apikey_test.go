@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	srv := newRecordingServer(t)
+	for _, tc := range []struct {
+		name               string
+		opts               []OptReqParamsOption
+		header, param      string
+		wantHdr, wantQuery string
+	}{
+		{"header default", []OptReqParamsOption{WithAPIKeyInHeader("k1", "")}, DefaultAPIKeyHeader, DefaultAPIKeyParam, "k1", ""},
+		{"header named", []OptReqParamsOption{WithAPIKeyInHeader("k2", "X-Key")}, "X-Key", "X-Key", "k2", ""},
+		{"query default", []OptReqParamsOption{WithAPIKeyInQuery("k3", "")}, DefaultAPIKeyHeader, DefaultAPIKeyParam, "", "k3"},
+		{"query named", []OptReqParamsOption{WithAPIKeyInQuery("k4", "key")}, "key", "key", "", "k4"},
+		{"last wins", []OptReqParamsOption{WithAPIKeyInHeader("k5", ""), WithAPIKeyInQuery("k6", "")},
+			DefaultAPIKeyHeader, DefaultAPIKeyParam, "", "k6"},
+	} {
+		res, err := callWithAuth(t, srv.URL+"?a=1", tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		readBody(t, res)
+		r, _ := srv.last(t)
+		if got := r.Header.Get(tc.header); got != tc.wantHdr {
+			t.Errorf("%s: header %s = %q, want %q", tc.name, tc.header, got, tc.wantHdr)
+		}
+		if got := r.URL.Query().Get(tc.param); got != tc.wantQuery {
+			t.Errorf("%s: query %s = %q, want %q", tc.name, tc.param, got, tc.wantQuery)
+		}
+		if r.URL.Query().Get("a") != "1" {
+			t.Errorf("%s: query of url lost, got %q", tc.name, r.URL.RawQuery)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("%s: api key request has Authorization %q", tc.name, r.Header.Get("Authorization"))
+		}
+	}
+}
+
+func TestAPIKeyInvalid(t *testing.T) {
+	srv := newRecordingServer(t)
+	for name, tc := range map[string]struct {
+		opts []OptReqParamsOption
+		want error
+	}{
+		"empty header key": {[]OptReqParamsOption{WithAPIKeyInHeader("", "")}, ErrInvalidOption},
+		"empty query key":  {[]OptReqParamsOption{WithAPIKeyInQuery("", "")}, ErrInvalidOption},
+		"with basic auth":  {[]OptReqParamsOption{WithAPIKeyInHeader("k", ""), WithBasicAuth("u", "p")}, ErrConflictingOptions},
+		"with bearer":      {[]OptReqParamsOption{WithAPIKeyInQuery("k", ""), WithBearerToken("t")}, ErrConflictingOptions},
+	} {
+		if _, err := callWithAuth(t, srv.URL, tc.opts...); !errors.Is(err, tc.want) {
+			t.Errorf("%s: error %v, want %v", name, err, tc.want)
+		}
+	}
+	if n := srv.count(); n != 0 {
+		t.Errorf("server got %d requests, want none", n)
+	}
+}
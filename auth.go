@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrConflictingOptions is returned by CustomHTTPRequest when options which can't be used together were set
+var ErrConflictingOptions = errors.New("conflicting options")
+
+// WithBasicAuth uses HTTP Basic authentication instead of bearer token from MyLoginAPI.
+// It can't be combined with WithUseInvalidToken
+func WithBasicAuth(username, password string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.useBasicAuth = true
+		s.basicAuthUser = username
+		s.basicAuthPasswd = password
+	}
+}
+
+// WithNoAuth skips all authentication headers, for public endpoints or when transport itself handles auth
+func WithNoAuth() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.noAuth = true
+	}
+}
+
+// WithBearerToken sends an already obtained token as bearer token, MyLoginAPI is not called at all.
+// An empty token makes CustomHTTPRequest fail before any network call
+func WithBearerToken(token string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.useBearerToken = true
+		s.bearerToken = token
+	}
+}
+
+// loginToken gets bearer token for email and passwd from MyLoginAPI, it is a var so tests can stub the login
+var loginToken = func(ctx context.Context, email, passwd string) (string, error) {
+	resp, err := MyLoginAPI(ctx, email, passwd)
+	if err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// applyAuth sets authentication on req as configured in p, replacing any Authorization header set before.
+// by default it calls your login api with email and passwd to get a valid bearer token
+func applyAuth(ctx context.Context, req *http.Request, email, passwd string, p *OptReqParams) error {
+	switch {
+	case p.noAuth:
+		return nil
+	case p.useDigestAuth:
+		return nil // digest handshake is done by the transport, see digest.go
+	case p.sigV4 != nil:
+		return nil // request is signed as the last step, see sigv4.go
+	case p.useBasicAuth:
+		req.SetBasicAuth(p.basicAuthUser, p.basicAuthPasswd)
+	case p.useBearerToken:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.bearerToken))
+	case p.apiKey != "":
+		applyAPIKey(req, p)
+	case p.jwt != nil:
+		token, err := p.jwt.token()
+		if err != nil {
+			return fmt.Errorf("error signing jwt %w", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	case p.useInvalidToken: // default set to false in constructor NewOptReqParams
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", "Invalid Token"))
+	case p.dryRun:
+		return nil // rest of the modes need the network to get a token
+	case p.tokenSource != nil:
+		token, err := p.tokenSource.token(ctx, newHTTPClient(p))
+		if err != nil {
+			return fmt.Errorf("error getting oauth2 token %w", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	case p.tokenRefresher != nil:
+		token, err := p.tokenRefresher.get(ctx)
+		if err != nil {
+			return fmt.Errorf("error refreshing token %w", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	default:
+		// call your login api to get valid token
+		token, err := loginToken(ctx, email, passwd)
+		if err != nil {
+			msg := fmt.Sprintf("error in login with user provided credentials %v", err)
+			return errors.New(msg)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// BatchRequest is one request of BatchExecute, Options are applied on top of options shared by the batch
+type BatchRequest struct {
+	URL     string
+	Options []OptReqParamsOption
+}
+
+// BatchResult is outcome of one BatchRequest, same as what CustomHTTPRequest would return for it
+type BatchResult struct {
+	Response *http.Response
+	Err      error
+}
+
+// WithConcurrencyLimit sets how many requests of BatchExecute are in flight at the same time,
+// zero (default) means all of them at once
+func WithConcurrencyLimit(n int) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.concurrencyLimit = n
+	}
+}
+
+// BatchExecute fires all requests in parallel with opts shared by all of them. Requests authenticating with
+// MyLoginAPI share one login, requests with an auth mode of their own like WithNoAuth keep it. Shared opts
+// can't have a body reader of WithBody as every request would read the same one, use WithRawBody for a
+// shared body. Results are in the same order as requests, error is only returned when login failed or for
+// a shared body reader. Caller has to close body of every response
+func BatchExecute(ctx context.Context, email, passwd string, requests []BatchRequest,
+	opts ...OptReqParamsOption) ([]BatchResult, error) {
+	shared := NewOptReqParams(opts...)
+	if shared.body != nil {
+		return nil, fmt.Errorf("%w: body reader shared by batch requests, use WithRawBody", ErrInvalidOption)
+	}
+	params := make([]*OptReqParams, len(requests))
+	var needsLogin bool
+	for i, r := range requests {
+		params[i] = shared.With(r.Options...)
+		needsLogin = needsLogin || params[i].usesLogin()
+	}
+	if needsLogin {
+		token, err := loginToken(ctx, email, passwd)
+		if err != nil {
+			return nil, fmt.Errorf("error in login with user provided credentials %w", err)
+		}
+		for i, p := range params {
+			if p.usesLogin() {
+				params[i] = p.With(WithBearerToken(token))
+			}
+		}
+	}
+
+	limit := shared.concurrencyLimit
+	if limit <= 0 || limit > len(requests) {
+		limit = len(requests)
+	}
+	sem := make(chan struct{}, limit)
+	results := make([]BatchResult, len(requests))
+	var wg sync.WaitGroup
+	for i, r := range requests {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			res, err := CustomHTTPRequest(ctx, r.URL, email, passwd, params[i])
+			results[i] = BatchResult{Response: res, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// usesLogin tells whether p authenticates with bearer token from MyLoginAPI, that is no other auth mode is set
+func (p *OptReqParams) usesLogin() bool {
+	return !p.noAuth && !p.useDigestAuth && p.sigV4 == nil && !p.useBasicAuth && !p.useBearerToken &&
+		p.jwt == nil && p.apiKey == "" && !p.useInvalidToken && !p.dryRun && p.tokenSource == nil && p.tokenRefresher == nil
+}
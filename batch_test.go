@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchExecuteOrderAndLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(r.URL.Path))
+	})
+
+	var requests []BatchRequest
+	for i := range 10 {
+		requests = append(requests, BatchRequest{URL: fmt.Sprintf("%s/%d", srv.URL, i)})
+	}
+	results, err := BatchExecute(t.Context(), "", "", requests, WithNoAuth(), WithConcurrencyLimit(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("request %d: %v", i, r.Err)
+		}
+		if body := readBody(t, r.Response); body != fmt.Sprintf("/%d", i) {
+			t.Errorf("result %d has body %q", i, body)
+		}
+	}
+	if m := maxInFlight.Load(); m > 3 {
+		t.Errorf("%d requests in flight, limit is 3", m)
+	}
+}
+
+func TestBatchExecuteKeepsOwnAuthMode(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	})
+	// neither mode may get the token of the login for the rest of the batch
+	results, err := BatchExecute(t.Context(), "", "", []BatchRequest{
+		{URL: srv.URL, Options: []OptReqParamsOption{WithNoAuth()}},
+		{URL: srv.URL, Options: []OptReqParamsOption{WithUseInvalidToken(true)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"", "Bearer Invalid Token"} {
+		if results[i].Err != nil {
+			t.Fatalf("request %d: %v", i, results[i].Err)
+		}
+		if got := readBody(t, results[i].Response); got != want {
+			t.Errorf("request %d sent Authorization %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestBatchExecuteRejectsSharedBodyReader(t *testing.T) {
+	_, err := BatchExecute(t.Context(), "", "", []BatchRequest{{URL: "http://example.com"}},
+		WithNoAuth(), WithBody(strings.NewReader("body")))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("got %v, want ErrInvalidOption", err)
+	}
+}
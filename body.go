@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// WithJSONBody marshals v with encoding/json (json.Marshaler implementations are honoured) and sends it as
+// request body with ContentTypeJSON. v is marshaled right away, a marshal error is returned by
+// CustomHTTPRequest, use WithJSONBodyE to get it at construction time instead
+func WithJSONBody(v any) OptReqParamsOption {
+	b, err := json.Marshal(v)
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: json body: %v", ErrInvalidOption, err))
+			return
+		}
+		if err := setEncodedBody(s, "json", b, ContentTypeJSON); err != nil {
+			s.optErrs = append(s.optErrs, err)
+		}
+	}
+}
+
+// WithXMLBody marshals v with encoding/xml and sends it as request body with ContentTypeXML. Same as with
+// WithJSONBody a marshal error is returned by CustomHTTPRequest, use WithXMLBodyE to get it at construction time
+func WithXMLBody(v any) OptReqParamsOption {
+	b, err := xml.Marshal(v)
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: xml body: %v", ErrInvalidOption, err))
+			return
+		}
+		if err := setEncodedBody(s, "xml", b, ContentTypeXML); err != nil {
+			s.optErrs = append(s.optErrs, err)
+		}
+	}
+}
+
+// WithFormBody sends values url encoded with ContentTypeForm. It merges with fields added before by
+// WithFormBody or WithFormField. Mixing it with another encoded body like WithJSONBody makes
+// CustomHTTPRequest fail with ErrConflictingOptions
+func WithFormBody(values url.Values) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		if err := addFormValues(s, values); err != nil {
+			s.optErrs = append(s.optErrs, err)
+		}
+	}
+}
+
+// WithFormField adds a single form field, multiple calls accumulate same as WithQueryParamSingle
+func WithFormField(key, value string) OptReqParamsOption {
+	return withKind("WithFormField", WithFormBody(url.Values{key: {value}}))
+}
+
+// addFormValues merges values into form body of s and re-encodes it
+func addFormValues(s *OptReqParams, values url.Values) error {
+	form := cloneValues(s.formBody)
+	if form == nil {
+		form = make(url.Values)
+	}
+	for k, vals := range values {
+		form[k] = append(form[k], vals...)
+	}
+	if err := setEncodedBody(s, "form", []byte(form.Encode()), ContentTypeForm); err != nil {
+		return err
+	}
+	s.formBody = form
+	return nil
+}
+
+// WithMultipartBody builds a multipart/form-data body by passing a multipart.Writer to fn.
+// fn is run right away and the written parts are buffered, content type with boundary is set to go with it.
+// Error from fn or mixing it with another encoded body is returned by CustomHTTPRequest
+func WithMultipartBody(fn func(*multipart.Writer) error) OptReqParamsOption {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	err := fn(w)
+	if err == nil {
+		err = w.Close()
+	}
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: multipart body: %v", ErrInvalidOption, err))
+			return
+		}
+		if err := setEncodedBody(s, "multipart", buf.Bytes(), w.FormDataContentType()); err != nil {
+			s.optErrs = append(s.optErrs, err)
+		}
+	}
+}
+
+// WithFileUpload sends content of r as a single file named filename in form field fieldName
+func WithFileUpload(fieldName, filename string, r io.Reader) OptReqParamsOption {
+	return withKind("WithFileUpload", WithMultipartBody(func(w *multipart.Writer) error {
+		part, err := w.CreateFormFile(fieldName, filename)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, r)
+		return err
+	}))
+}
+
+// WithRawBody sends data as it is, for already serialized bodies like protobuf or binary data.
+// Unlike a reader given to WithBody, length is known so Content-Length is set instead of chunked encoding.
+// Use WithContentType to set matching content type
+func WithRawBody(data []byte) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.body = nil
+		s.bodyBytes = data
+		s.bodyKind = ""
+		s.bodyFile = ""
+	}
+}
+
+// WithBodyFromFile streams file at path as request body without buffering it in memory.
+// File is opened by CustomHTTPRequest, so a missing file is reported there as *os.PathError
+// before any network call. Content-Length is set from file size
+func WithBodyFromFile(path string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.body = nil
+		s.bodyBytes = nil
+		s.bodyKind = ""
+		s.bodyFile = path
+	}
+}
+
+// setFileBody opens path and sets it as body of req, returned func closes the file and must be called
+// once req is done. Body is read again from the file for retries instead of being buffered, every reader
+// made by GetBody has its own offset so dumping, signing or hedging doesn't drain the body being sent
+func setFileBody(req *http.Request, path string) (func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	// transport closes request body after every attempt, so it only gets a view of the file
+	size := fi.Size()
+	req.Body = io.NopCloser(io.NewSectionReader(f, 0, size))
+	req.ContentLength = size
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(f, 0, size)), nil
+	}
+	if fi.Size() == 0 {
+		req.Body = http.NoBody
+	}
+	return func() { _ = f.Close() }, nil
+}
+
+// setEncodedBody stores b as body and sets content type to go with it. kind names the encoding, only one
+// encoded body kind can be used per params and mixing them is reported as ErrConflictingOptions
+func setEncodedBody(s *OptReqParams, kind string, b []byte, contentType string) error {
+	if s.bodyKind != "" && s.bodyKind != kind {
+		return fmt.Errorf("%w: %s body and %s body", ErrConflictingOptions, s.bodyKind, kind)
+	}
+	s.bodyKind = kind
+	s.body = nil
+	s.bodyBytes = b
+	s.bodyFile = ""
+	s.contentType = contentType
+	return nil
+}
+
+// requestBody returns body reader for a new request. Body given as bytes gets a fresh reader every time, so
+// same params can be used for many requests. A reader from WithBody is buffered when it may be read more than once
+func requestBody(p *OptReqParams) (io.Reader, error) {
+	if p.bodyBytes != nil {
+		return bytes.NewReader(p.bodyBytes), nil
+	}
+	if p.needsReplayableBody() && p.body != nil {
+		// buffer the body so it can be read more than once
+		b, err := io.ReadAll(p.body)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(b), nil
+	}
+	return p.body, nil
+}
+
+// needsReplayableBody tells whether some option of p has to read request body more than once,
+// like retries sending it again or signing reading it before it is sent. Bytes and file bodies are always
+// replayable, only a reader from WithBody has to be buffered for it
+func (p *OptReqParams) needsReplayableBody() bool {
+	return p.maxRetries > 0 || p.useDigestAuth || p.hmacSecret != nil || p.sigV4 != nil ||
+		p.hedgeDelay > 0 || p.debugDump != nil
+}
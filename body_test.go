@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTempFile writes content to a file in a temp dir of the test and returns its path
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "body")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// echoBody replies with request body, failing the test if its length doesn't match Content-Length
+func echoBody(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %v", err)
+		}
+		if r.ContentLength != int64(len(b)) {
+			t.Errorf("Content-Length %d, body has %d bytes", r.ContentLength, len(b))
+		}
+		_, _ = w.Write(b)
+	}
+}
+
+func TestBodyFromFile(t *testing.T) {
+	const content = "file content"
+	path := writeTempFile(t, content)
+	srv := newCountingServer(t, false, echoBody(t))
+
+	for name, opt := range map[string]OptReqParamsOption{
+		"plain":   Compose(),
+		"dump":    WithDebugDump(io.Discard),
+		"hmac":    WithHMACSignature([]byte("secret"), "", nil),
+		"hedged":  WithHedgedDelay(time.Millisecond),
+		"retries": WithMaxRetries(2),
+	} {
+		_, body := mustCall(t, srv.URL, WithMethod(http.MethodPost), WithBodyFromFile(path), opt)
+		if body != content {
+			t.Errorf("%s: server got %q, want %q", name, body, content)
+		}
+	}
+}
+
+func TestBodyFromFileRetried(t *testing.T) {
+	const content = "retried content"
+	path := writeTempFile(t, content)
+	var got []string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = append(got, string(b))
+		if len(got) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	mustCall(t, srv.URL, WithMethod(http.MethodPut), WithBodyFromFile(path), WithMaxRetries(1),
+		WithRetryBackoff(time.Millisecond, time.Millisecond, 1))
+	if strings.Join(got, ",") != content+","+content {
+		t.Errorf("attempts sent %q, want body twice", got)
+	}
+}
+
+func TestBodyFromMissingFile(t *testing.T) {
+	srv := newCountingServer(t, false, echoBody(t))
+	_, err := call(t, srv.URL, WithMethod(http.MethodPost), WithBodyFromFile(filepath.Join(t.TempDir(), "none")))
+	if !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist error", err)
+	}
+	if n := srv.conns.Load(); n != 0 {
+		t.Errorf("%d connections made for missing file", n)
+	}
+}
+
+func TestRawBody(t *testing.T) {
+	srv := newCountingServer(t, false, echoBody(t))
+	data := []byte{0, 1, 2, 3}
+	p := NewOptReqParams(WithNoAuth(), WithMethod(http.MethodPost), WithRawBody(data))
+	for range 2 { // same params can be used again, body is not consumed
+		res, err := CustomHTTPRequest(t.Context(), srv.URL, "", "", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := readBody(t, res); !bytes.Equal([]byte(body), data) {
+			t.Errorf("server got %v, want %v", []byte(body), data)
+		}
+	}
+}
+
+func TestJSONBodyRoundTrip(t *testing.T) {
+	type item struct {
+		Name  string   `json:"name"`
+		Count int      `json:"count"`
+		Tags  []string `json:"tags"`
+	}
+	want := item{Name: "widget", Count: 3, Tags: []string{"a", "b"}}
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithMethod(http.MethodPost), WithJSONBody(want))
+
+	req, body := srv.last(t)
+	if ct := req.Header.Get("Content-Type"); ct != ContentTypeJSON {
+		t.Errorf("Content-Type %q", ct)
+	}
+	var got item
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("server got %q: %v", body, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("server decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestJSONBodyMarshalError(t *testing.T) {
+	srv := newRecordingServer(t)
+	_, err := call(t, srv.URL, WithMethod(http.MethodPost), WithJSONBody(make(chan int)))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+	if n := srv.count(); n != 0 {
+		t.Errorf("server got %d requests", n)
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithMethod(http.MethodPost), WithMultipartBody(func(w *multipart.Writer) error {
+		if err := w.WriteField("title", "report"); err != nil {
+			return err
+		}
+		part, err := w.CreateFormFile("file", "report.csv")
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(part, "a,b\n1,2\n")
+		return err
+	}))
+
+	req, body := srv.last(t)
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("Content-Type %q: %v", req.Header.Get("Content-Type"), err)
+	}
+	r := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	want := []struct{ form, file, content string }{
+		{"title", "", "report"},
+		{"file", "report.csv", "a,b\n1,2\n"},
+	}
+	for _, w := range want {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatalf("part %s: %v", w.form, err)
+		}
+		content, _ := io.ReadAll(part)
+		if part.FormName() != w.form || part.FileName() != w.file || string(content) != w.content {
+			t.Errorf("part %q file %q content %q, want %+v", part.FormName(), part.FileName(), content, w)
+		}
+	}
+	if _, err := r.NextPart(); err != io.EOF {
+		t.Errorf("after last part got %v, want io.EOF", err)
+	}
+}
+
+func TestFileUpload(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithMethod(http.MethodPost), WithFileUpload("doc", "a.txt", strings.NewReader("hello")))
+	req, body := srv.last(t)
+	req.Body = io.NopCloser(strings.NewReader(body))
+	f, header, err := req.FormFile("doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content, _ := io.ReadAll(f)
+	if header.Filename != "a.txt" || string(content) != "hello" {
+		t.Errorf("file %q with %q", header.Filename, content)
+	}
+}
+
+func TestMultipartBodyError(t *testing.T) {
+	werr := errors.New("write failed")
+	_, err := call(t, "http://127.0.0.1:1", WithMultipartBody(func(*multipart.Writer) error { return werr }))
+	if !errors.Is(err, ErrInvalidOption) || !strings.Contains(err.Error(), "write failed") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestFormBody(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithMethod(http.MethodPost), WithFormBody(url.Values{"a": {"1", "2"}, "q": {"x y&z"}}),
+		WithFormField("a", "3"), WithFormField("b", "4"))
+
+	req, body := srv.last(t)
+	if ct := req.Header.Get("Content-Type"); ct != ContentTypeForm {
+		t.Errorf("Content-Type %q", ct)
+	}
+	got, err := url.ParseQuery(body)
+	if err != nil {
+		t.Fatalf("server got %q: %v", body, err)
+	}
+	want := url.Values{"a": {"1", "2", "3"}, "b": {"4"}, "q": {"x y&z"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("server decoded %v, want %v", got, want)
+	}
+}
+
+func TestFormBodyConflictsWithJSONBody(t *testing.T) {
+	srv := newRecordingServer(t)
+	_, err := call(t, srv.URL, WithMethod(http.MethodPost), WithJSONBody(1), WithFormField("a", "1"))
+	if !errors.Is(err, ErrConflictingOptions) {
+		t.Errorf("err = %v, want ErrConflictingOptions", err)
+	}
+	if n := srv.count(); n != 0 {
+		t.Errorf("server got %d requests", n)
+	}
+}
+
+func TestXMLBodyRoundTrip(t *testing.T) {
+	type line struct {
+		SKU string `xml:"sku,attr"`
+		Qty int    `xml:"qty"`
+	}
+	type order struct {
+		XMLName xml.Name `xml:"order"`
+		ID      string   `xml:"id,attr"`
+		Note    string   `xml:"note,omitempty"`
+		Lines   []line   `xml:"lines>line"`
+	}
+	want := order{XMLName: xml.Name{Local: "order"}, ID: "o-1", Note: "fragile & <urgent>",
+		Lines: []line{{SKU: "a", Qty: 2}, {SKU: "b", Qty: 1}}}
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithMethod(http.MethodPost), WithXMLBody(want))
+
+	req, body := srv.last(t)
+	if ct := req.Header.Get("Content-Type"); ct != ContentTypeXML {
+		t.Errorf("Content-Type %q", ct)
+	}
+	var got order
+	if err := xml.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("server got %q: %v", body, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("server decoded %+v, want %+v", got, want)
+	}
+}
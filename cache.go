@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachedResponse is what ResponseCache keeps of a response, it must not be modified once cached
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	VaryHeader http.Header // values request had for headers named in Vary of the response
+}
+
+// ResponseCache keeps responses for WithResponseCache. Keys are made of method, url and a hash of
+// request headers and settings which can change the response, see requestKey. Credentials are never
+// part of a key in plain text
+type ResponseCache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, r *CachedResponse, ttl time.Duration)
+}
+
+// WithResponseCache answers GET and HEAD requests from cache when it has a response for them, without
+// any network call, and caches every 200 response for ttl. Responses are only shared by requests going to
+// the same url with same credentials, Range, Accept headers and headers named in Vary of the response,
+// a response with Vary: * is not cached. Cache-Control of the response is not taken into account.
+// NewMemoryResponseCache gives a ready to use cache
+func WithResponseCache(cache ResponseCache, ttl time.Duration) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.responseCache = cache
+		s.responseCacheTTL = ttl
+	}
+}
+
+// memoryCacheSweepInterval is how often Set of MemoryResponseCache looks for expired responses of
+// other keys, so responses which are never looked up again don't stay in memory
+const memoryCacheSweepInterval = time.Minute
+
+// MemoryResponseCache is a ResponseCache backed by sync.Map, safe for concurrent use.
+// Expired responses are dropped when they are looked up, and all of them by Set once a minute
+type MemoryResponseCache struct {
+	m         sync.Map
+	lastSweep atomic.Int64 // unix nano time of the last sweep
+}
+
+type memoryCacheEntry struct {
+	r       *CachedResponse
+	expires time.Time // zero means never
+}
+
+// NewMemoryResponseCache returns an empty in memory ResponseCache
+func NewMemoryResponseCache() *MemoryResponseCache {
+	return &MemoryResponseCache{}
+}
+
+// Get returns response cached for key unless it expired
+func (c *MemoryResponseCache) Get(key string) (*CachedResponse, bool) {
+	v, ok := c.m.Load(key)
+	if !ok {
+		return nil, false
+	}
+	e := v.(memoryCacheEntry)
+	if !e.expires.IsZero() && !time.Now().Before(e.expires) {
+		c.m.CompareAndDelete(key, v)
+		return nil, false
+	}
+	return e.r, true
+}
+
+// Set caches r for key for ttl, zero ttl means until replaced
+func (c *MemoryResponseCache) Set(key string, r *CachedResponse, ttl time.Duration) {
+	e := memoryCacheEntry{r: r}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	c.m.Store(key, e)
+	c.sweep(time.Now())
+}
+
+// sweep drops expired responses, unless the last sweep was less than memoryCacheSweepInterval ago
+func (c *MemoryResponseCache) sweep(now time.Time) {
+	last := c.lastSweep.Load()
+	if now.UnixNano()-last < int64(memoryCacheSweepInterval) || !c.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	c.m.Range(func(key, v any) bool {
+		if e := v.(memoryCacheEntry); !e.expires.IsZero() && !now.Before(e.expires) {
+			c.m.CompareAndDelete(key, v)
+		}
+		return true
+	})
+}
+
+// doCached answers req from response cache of p if it can, otherwise fires it and caches the response
+func doCached(ctx context.Context, client *http.Client, req *http.Request, p *OptReqParams) (*http.Response, error) {
+	if p.responseCache == nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return doCoalesced(ctx, client, req, p)
+	}
+
+	key := requestKey(req, p)
+	if c, ok := p.responseCache.Get(key); ok && c.matchesVary(req) {
+		return c.response(req), nil
+	}
+
+	res, err := doCoalesced(ctx, client, req, p)
+	if err != nil || res.StatusCode != http.StatusOK || slices.Contains(varyNames(res.Header), "*") {
+		return res, err
+	}
+	b, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	vary := make(http.Header)
+	for _, name := range varyNames(res.Header) {
+		vary[name] = slices.Clone(req.Header.Values(name))
+	}
+	p.responseCache.Set(key, &CachedResponse{StatusCode: res.StatusCode, Header: res.Header.Clone(), Body: b,
+		VaryHeader: vary}, p.responseCacheTTL)
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	return res, nil
+}
+
+// varyNames returns canonical header names listed in Vary of response header h
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// matchesVary tells whether req has same values for headers named in Vary as the request c was cached for
+func (c *CachedResponse) matchesVary(req *http.Request) bool {
+	for name, values := range c.VaryHeader {
+		if !slices.Equal(req.Header.Values(name), values) {
+			return false
+		}
+	}
+	return true
+}
+
+// response makes a new response for req out of c
+func (c *CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode)),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
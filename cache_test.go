@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCacheServer replies with Authorization and Accept-Language of the request, setting vary as Vary
+func newCacheServer(t *testing.T, vary string) (*countingServer, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if vary != "" {
+			w.Header().Set("Vary", vary)
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("Accept-Language")))
+	})
+	return srv, &calls
+}
+
+func TestResponseCache(t *testing.T) {
+	srv, calls := newCacheServer(t, "")
+	cache := NewMemoryResponseCache()
+	for range 3 {
+		_, body := mustCall(t, srv.URL, WithResponseCache(cache, time.Minute), WithHeader("Authorization", "Bearer a"))
+		if body != "Bearer a|" {
+			t.Errorf("got %q", body)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server got %d calls, want 1", n)
+	}
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	srv, calls := newCacheServer(t, "")
+	cache := NewMemoryResponseCache()
+	mustCall(t, srv.URL, WithResponseCache(cache, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	mustCall(t, srv.URL, WithResponseCache(cache, 10*time.Millisecond))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d calls, want 2", n)
+	}
+}
+
+func TestResponseCacheKeepsCredentialsApart(t *testing.T) {
+	srv, calls := newCacheServer(t, "")
+	cache := NewMemoryResponseCache()
+	for _, token := range []string{"a", "b", "a", "b"} {
+		_, body := mustCall(t, srv.URL, WithResponseCache(cache, time.Minute), WithHeader("Authorization", "Bearer "+token))
+		if body != "Bearer "+token+"|" {
+			t.Errorf("token %s got %q", token, body)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d calls, want one per token", n)
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	srv, calls := newCacheServer(t, "Accept-Language")
+	cache := NewMemoryResponseCache()
+	for _, lang := range []string{"de", "fr", "fr"} {
+		_, body := mustCall(t, srv.URL, WithResponseCache(cache, time.Minute), WithAcceptLanguage(lang))
+		if body != "|"+lang {
+			t.Errorf("language %s got %q", lang, body)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d calls, want 2", n)
+	}
+}
+
+func TestResponseCacheVaryStar(t *testing.T) {
+	srv, calls := newCacheServer(t, "*")
+	cache := NewMemoryResponseCache()
+	for range 2 {
+		mustCall(t, srv.URL, WithResponseCache(cache, time.Minute))
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d calls, want 2", n)
+	}
+}
+
+func TestResponseCacheOnlyGET(t *testing.T) {
+	srv, calls := newCacheServer(t, "")
+	cache := NewMemoryResponseCache()
+	for range 2 {
+		mustCall(t, srv.URL, WithResponseCache(cache, time.Minute), WithMethod(http.MethodPost))
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server got %d calls, want 2", n)
+	}
+}
+
+func TestMemoryResponseCacheSweepsExpired(t *testing.T) {
+	srv, calls := newCacheServer(t, "")
+	cache := NewMemoryResponseCache()
+	mustCall(t, srv.URL+"/short", WithResponseCache(cache, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	mustCall(t, srv.URL+"/long", WithResponseCache(cache, time.Hour))
+	if n := cacheEntries(cache); n != 2 {
+		t.Fatalf("cache has %d entries before the sweep interval passed, want 2", n)
+	}
+
+	// next Set after the interval drops the expired response which was never looked up again
+	cache.lastSweep.Add(-int64(memoryCacheSweepInterval))
+	mustCall(t, srv.URL+"/other", WithResponseCache(cache, time.Hour))
+	if n := cacheEntries(cache); n != 2 {
+		t.Errorf("cache has %d entries after the sweep, want 2", n)
+	}
+	mustCall(t, srv.URL+"/long", WithResponseCache(cache, time.Hour))
+	if n := calls.Load(); n != 3 {
+		t.Errorf("server got %d calls, want unexpired response still answered from cache", n)
+	}
+}
+
+func cacheEntries(c *MemoryResponseCache) int {
+	n := 0
+	c.m.Range(func(any, any) bool { n++; return true })
+	return n
+}
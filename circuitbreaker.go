@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CustomHTTPRequest without making any call while circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets every request through, it is the initial state
+	StateClosed State = iota
+	// StateOpen fails every request with ErrCircuitOpen
+	StateOpen
+	// StateHalfOpen lets a single probe request through to decide whether to close or open again
+	StateHalfOpen
+)
+
+// String returns state name for logs
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// WithCircuitBreaker makes CustomHTTPRequest fail fast with ErrCircuitOpen for openDuration after threshold
+// consecutive failures. An error or a 5xx status counts as failure. After openDuration one probe request
+// is let through, its success closes the circuit and its failure opens it again.
+// Every params made with the returned option and their clones share the breaker, so every call made with
+// them is counted together even when params are built for each call
+func WithCircuitBreaker(threshold int, openDuration time.Duration) OptReqParamsOption {
+	cb := &circuitBreaker{threshold: threshold, openDuration: openDuration}
+	return func(s *OptReqParams) {
+		s.breaker = cb
+	}
+}
+
+// OnStateChange registers fn to be called with new state every time circuit breaker changes state
+func OnStateChange(fn func(State)) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.onStateChange = fn
+	}
+}
+
+// circuitBreaker is the state machine behind WithCircuitBreaker, safe for concurrent use
+type circuitBreaker struct {
+	threshold    int
+	openDuration time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int // consecutive failures while closed
+	openedAt time.Time
+	probing  bool // probe request in flight while half-open
+}
+
+// allow returns ErrCircuitOpen if request should not be made
+func (cb *circuitBreaker) allow(onChange func(State)) error {
+	cb.mu.Lock()
+	from := cb.state
+	var err error
+	switch cb.state {
+	case StateOpen:
+		if time.Since(cb.openedAt) < cb.openDuration {
+			err = ErrCircuitOpen
+			break
+		}
+		cb.state = StateHalfOpen
+		cb.probing = true
+	case StateHalfOpen:
+		if cb.probing {
+			err = ErrCircuitOpen
+			break
+		}
+		cb.probing = true
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	notifyStateChange(from, to, onChange)
+	return err
+}
+
+// record updates the breaker with outcome of a request let through by allow
+func (cb *circuitBreaker) record(failed bool, onChange func(State)) {
+	cb.mu.Lock()
+	from := cb.state
+	switch {
+	case cb.state == StateHalfOpen:
+		cb.probing = false
+		if failed {
+			cb.open()
+		} else {
+			cb.state = StateClosed
+			cb.failures = 0
+		}
+	case !failed:
+		cb.failures = 0
+	default:
+		cb.failures++
+		if cb.failures >= cb.threshold {
+			cb.open()
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	notifyStateChange(from, to, onChange)
+}
+
+// open must be called with mu held
+func (cb *circuitBreaker) open() {
+	cb.state = StateOpen
+	cb.openedAt = time.Now()
+	cb.failures = 0
+}
+
+// notifyStateChange calls onChange outside of the lock so it can't block the breaker
+func notifyStateChange(from, to State, onChange func(State)) {
+	if from != to && onChange != nil {
+		onChange(to)
+	}
+}
+
+// isFailure tells whether result of a call counts as failure for circuit breaker
+func isFailure(res *http.Response, err error) bool {
+	return err != nil || (res != nil && res.StatusCode >= http.StatusInternalServerError)
+}
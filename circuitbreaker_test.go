@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stateRecorder keeps states a circuit breaker went through
+type stateRecorder struct {
+	mu     sync.Mutex
+	states []State
+}
+
+func (r *stateRecorder) record(s State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, s)
+}
+
+func (r *stateRecorder) get() []State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]State(nil), r.states...)
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	const openFor = 50 * time.Millisecond
+	var failing atomic.Bool
+	var requests atomic.Int32
+	failing.Store(true)
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	// params are built for every call, breaker of the option is shared by all of them
+	states := &stateRecorder{}
+	breaker, onChange := WithCircuitBreaker(3, openFor), OnStateChange(states.record)
+	hits := func() int { return int(requests.Load()) }
+	do := func() error {
+		res, err := call(t, srv.URL, breaker, onChange)
+		readBody(t, res)
+		return err
+	}
+
+	for i := range 3 {
+		if err := do(); err != nil {
+			t.Fatalf("failure %d: %v, want 500 response", i+1, err)
+		}
+	}
+	if got := states.get(); !reflect.DeepEqual(got, []State{StateOpen}) {
+		t.Fatalf("states %v after 3 failures, want [open]", got)
+	}
+	before := hits()
+	if err := do(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("call while open: %v, want ErrCircuitOpen", err)
+	}
+	if hits() != before {
+		t.Error("call while open reached the server")
+	}
+
+	// failed probe opens circuit again
+	time.Sleep(openFor + 10*time.Millisecond)
+	if err := do(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := do(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("call after failed probe: %v, want ErrCircuitOpen", err)
+	}
+
+	// successful probe closes it
+	failing.Store(false)
+	time.Sleep(openFor + 10*time.Millisecond)
+	for i := range 3 {
+		if err := do(); err != nil {
+			t.Errorf("call %d after successful probe: %v", i+1, err)
+		}
+	}
+	want := []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}
+	if got := states.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("states %v, want %v", got, want)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	const openFor = 20 * time.Millisecond
+	arrived, release := make(chan struct{}), make(chan struct{})
+	var probing atomic.Bool
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if probing.Load() {
+			arrived <- struct{}{}
+			<-release
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	states := &stateRecorder{}
+	breaker, onChange := WithCircuitBreaker(1, openFor), OnStateChange(states.record)
+	res, _ := call(t, srv.URL, breaker, onChange)
+	readBody(t, res)
+
+	time.Sleep(openFor + 10*time.Millisecond)
+	probing.Store(true)
+	done := make(chan error)
+	go func() {
+		res, err := call(t, srv.URL, breaker, onChange)
+		readBody(t, res)
+		done <- err
+	}()
+	<-arrived
+	if _, err := call(t, srv.URL, breaker, onChange); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("second call while probe is in flight: %v, want ErrCircuitOpen", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if got, want := states.get(), []State{StateOpen, StateHalfOpen, StateClosed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("states %v, want %v", got, want)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	var calls atomic.Int32
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1)%2 == 1 { // failures never come two in a row
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	breaker := WithCircuitBreaker(2, time.Minute)
+	for i := range 6 {
+		res, err := call(t, srv.URL, breaker)
+		if err != nil {
+			t.Fatalf("call %d: %v, circuit opened without consecutive failures", i+1, err)
+		}
+		readBody(t, res)
+	}
+}
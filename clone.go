@@ -0,0 +1,95 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// Clone returns a deep copy of p so it can be changed without affecting calls which still use p.
+// maps and slices are copied, body reader and function values are shared because they can't be copied.
+// tls config is shared too, it must not be modified once used anyway and options changing it copy it first
+func (p *OptReqParams) Clone() *OptReqParams {
+	c := *p
+	c.queryParam = cloneValues(p.queryParam)
+	c.formBody = cloneValues(p.formBody)
+	c.headers = p.headers.Clone()
+	c.pathParams = maps.Clone(p.pathParams)
+	c.optErrs = slices.Clone(p.optErrs)
+	c.appliedOptions = slices.Clone(p.appliedOptions)
+	c.requiredOptions = slices.Clone(p.requiredOptions)
+	c.appliedKinds = slices.Clone(p.appliedKinds)
+	c.optionConflicts = slices.Clone(p.optionConflicts)
+	c.onResponse = slices.Clone(p.onResponse)
+	c.onRetry = slices.Clone(p.onRetry)
+	c.cookies = cloneCookies(p.cookies)
+	c.clientCerts = slices.Clone(p.clientCerts)
+	c.requestMiddleware = slices.Clone(p.requestMiddleware)
+	c.responseMiddleware = slices.Clone(p.responseMiddleware)
+	c.bodyTransformers = slices.Clone(p.bodyTransformers)
+	c.sensitiveHeaders = slices.Clone(p.sensitiveHeaders)
+	c.bodySanitizers = slices.Clone(p.bodySanitizers)
+	c.fallbackURLs = slices.Clone(p.fallbackURLs) // urls themselves are never modified
+	if p.baseURL != nil {
+		u := *p.baseURL
+		c.baseURL = &u
+	}
+	return &c
+}
+
+// With clones p and applies opts on the clone, p itself is left untouched
+func (p *OptReqParams) With(opts ...OptReqParamsOption) *OptReqParams {
+	c := p.Clone()
+	for _, o := range opts {
+		applyOption(c, o)
+	}
+	return c
+}
+
+// OptReqParamsSnapshot is a copy of params taken by Snapshot, it isn't affected by later changes to them
+type OptReqParamsSnapshot struct {
+	p *OptReqParams
+}
+
+// Snapshot takes a deep copy of p, same as Clone, which Restore gives back. nil p is same as NewOptReqParams()
+func Snapshot(p *OptReqParams) OptReqParamsSnapshot {
+	if p == nil {
+		p = NewOptReqParams()
+	}
+	return OptReqParamsSnapshot{p: p.Clone()}
+}
+
+// Restore returns params as they were when snapshot was taken. every call returns a new copy, so the
+// snapshot can be restored again after returned params were changed
+func (s OptReqParamsSnapshot) Restore() *OptReqParams {
+	if s.p == nil {
+		return NewOptReqParams() // zero value snapshot
+	}
+	return s.p.Clone()
+}
+
+// cloneValues deep copies v, nil stays nil
+func cloneValues(v url.Values) url.Values {
+	if v == nil {
+		return nil
+	}
+	c := make(url.Values, len(v))
+	for k, values := range v {
+		c[k] = slices.Clone(values)
+	}
+	return c
+}
+
+// cloneCookies copies cookies along with the slice, caller may still change one it gave to WithCookies
+func cloneCookies(cookies []*http.Cookie) []*http.Cookie {
+	if cookies == nil {
+		return nil
+	}
+	c := make([]*http.Cookie, len(cookies))
+	for i, cookie := range cookies {
+		cp := *cookie
+		c[i] = &cp
+	}
+	return c
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestCloneMapsAreIndependent(t *testing.T) {
+	orig := NewOptReqParams(
+		WithQueryParamSingle("q", "1"),
+		WithHeader("X-A", "a"),
+		WithPathParams(map[string]string{"id": "1"}),
+		WithBaseURL("http://example.com/v1/"),
+	)
+	c := orig.Clone()
+	c.queryParam.Add("q", "2")
+	c.queryParam.Set("new", "x")
+	c.headers.Add("X-A", "b")
+	c.headers.Set("X-B", "b")
+	c.pathParams["id"] = "2"
+	c.baseURL.Path = "/v2/"
+
+	if got := orig.queryParam["q"]; len(got) != 1 || got[0] != "1" || orig.queryParam.Has("new") {
+		t.Errorf("query of original changed: %v", orig.queryParam)
+	}
+	if got := orig.headers.Values("X-A"); len(got) != 1 || orig.headers.Get("X-B") != "" {
+		t.Errorf("headers of original changed: %v", orig.headers)
+	}
+	if orig.pathParams["id"] != "1" {
+		t.Errorf("path params of original changed: %v", orig.pathParams)
+	}
+	if orig.baseURL.Path != "/v1/" {
+		t.Errorf("base url of original changed: %v", orig.baseURL)
+	}
+}
+
+func TestWithLeavesOriginalUntouched(t *testing.T) {
+	srv := newRecordingServer(t)
+	base := NewOptReqParams(WithNoAuth(), WithHeader("X-A", "base"))
+	extended := base.With(WithHeader("X-A", "extra"), WithQueryParamSingle("q", "1"))
+
+	for _, tt := range []struct {
+		p         *OptReqParams
+		headers   int
+		wantQuery string
+	}{
+		{extended, 2, "q=1"},
+		{base, 1, ""},
+	} {
+		res, err := CustomHTTPRequest(context.Background(), srv.URL, "user@example.com", "passwd", tt.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readBody(t, res)
+		req, _ := srv.last(t)
+		if n := len(req.Header.Values("X-A")); n != tt.headers || req.URL.RawQuery != tt.wantQuery {
+			t.Errorf("got %d X-A headers and query %q, want %d and %q", n, req.URL.RawQuery, tt.headers, tt.wantQuery)
+		}
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	p := NewOptReqParams(WithQueryParamSingle("q", "1"), WithHeader("X-A", "a"),
+		WithCookies(&http.Cookie{Name: "id", Value: "1"}))
+	snap := Snapshot(p)
+
+	// changes to original after the snapshot, in place and with options
+	p.queryParam.Set("q", "changed")
+	p.queryParam.Add("new", "x")
+	p.headers.Set("X-A", "changed")
+	p.cookies[0].Value = "changed"
+	WithQueryParamSingle("other", "y")(p)
+
+	r := snap.Restore()
+	if got := r.queryParam.Encode(); got != "q=1" {
+		t.Errorf("restored query %q, want %q", got, "q=1")
+	}
+	if r.headers.Get("X-A") != "a" || r.cookies[0].Value != "1" {
+		t.Errorf("restored X-A %q, cookie %q, want a, 1", r.headers.Get("X-A"), r.cookies[0].Value)
+	}
+
+	// restoring again gives a fresh copy, changes to the first one don't leak into the snapshot
+	r.queryParam.Set("q", "restored-changed")
+	if got := snap.Restore().queryParam.Get("q"); got != "1" {
+		t.Errorf("second restore has q %q, want 1", got)
+	}
+}
+
+func TestSnapshotZeroValue(t *testing.T) {
+	for name, snap := range map[string]OptReqParamsSnapshot{"nil params": Snapshot(nil), "zero": {}} {
+		if r := snap.Restore(); r == nil || r.httpMethod != http.MethodGet {
+			t.Errorf("%s: restored %+v, want default params", name, r)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// WithRequestCoalescing makes concurrent GET and HEAD requests to the same url, query included, share
+// one call through group. Only requests with same credentials and same Range and Accept headers share a call,
+// see requestKey. Every caller gets its own copy of the response and can read the body on its own.
+// Shared call runs with context of the caller which started it
+func WithRequestCoalescing(group *singleflight.Group) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.coalesceGroup = group
+	}
+}
+
+// sharedResponse is response of a coalesced call with its body read
+type sharedResponse struct {
+	res  *http.Response
+	body []byte
+}
+
+// doCoalesced fires req with retries like doWithRetry, sharing the call with other callers if there is a group
+func doCoalesced(ctx context.Context, client *http.Client, req *http.Request, p *OptReqParams) (*http.Response, error) {
+	if p.coalesceGroup == nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return doWithRetry(ctx, client, req, p)
+	}
+
+	v, err, _ := p.coalesceGroup.Do(requestKey(req, p), func() (any, error) {
+		res, err := doWithRetry(ctx, client, req, p)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedResponse{res: res, body: b}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// every caller gets a copy since it may change headers or body later on
+	shared := v.(*sharedResponse)
+	res := *shared.res
+	res.Header = shared.res.Header.Clone()
+	res.Trailer = shared.res.Trailer.Clone()
+	res.Body = io.NopCloser(bytes.NewReader(shared.body))
+	return &res, nil
+}
+
+// keyHeaders are request headers which change the response, for requestKey
+var keyHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Range", "Accept", "Accept-Encoding",
+	"Accept-Language"}
+
+// requestKey identifies what req asks for, for sharing its response through cache or coalescing. Besides
+// method and url it covers headers which change the response, credentials in custom headers and settings
+// which authenticate outside of headers like cookie jar, client certificates and digest auth. Those are hashed
+// so the key doesn't reveal them
+func requestKey(req *http.Request, p *OptReqParams) string {
+	h := sha256.New()
+	for _, name := range slices.Concat(keyHeaders, sensitiveHeaderNames(p)) {
+		fmt.Fprintf(h, "%s=%q\n", http.CanonicalHeaderKey(name), req.Header.Values(name))
+	}
+	fmt.Fprintf(h, "jar=%p\ntls=%p%s\ndigest=%q\n", p.cookieJar, p.tlsBase, p.tlsKey, p.digestUser)
+	return fmt.Sprintf("%s %s %x", req.Method, req.URL, h.Sum(nil))
+}
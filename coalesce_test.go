@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// blockingServer holds every request until release is closed, arrived gets a value for each request
+type blockingServer struct {
+	*countingServer
+	calls   atomic.Int32
+	arrived chan struct{}
+	release chan struct{}
+}
+
+func newBlockingServer(t *testing.T) *blockingServer {
+	s := &blockingServer{arrived: make(chan struct{}, 10), release: make(chan struct{})}
+	s.countingServer = newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		s.arrived <- struct{}{}
+		<-s.release
+		_, _ = w.Write([]byte(r.Header.Get("Authorization") + r.Header.Get("Range")))
+	})
+	return s
+}
+
+// coalescedCalls makes a call for every options, second one starting while first is in flight,
+// and returns bodies they got
+func coalescedCalls(t *testing.T, srv *blockingServer, wantShared bool, opts ...[]OptReqParamsOption) []string {
+	t.Helper()
+	bodies := make([]string, len(opts))
+	var wg sync.WaitGroup
+	for i, o := range opts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := call(t, srv.URL, o...)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			bodies[i] = readBody(t, res)
+		}()
+		if i == 0 || !wantShared {
+			select {
+			case <-srv.arrived:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("call %d didn't reach the server", i)
+			}
+		} else {
+			time.Sleep(50 * time.Millisecond) // let it join the call in flight
+		}
+	}
+	close(srv.release)
+	wg.Wait()
+	return bodies
+}
+
+func TestRequestCoalescing(t *testing.T) {
+	srv := newBlockingServer(t)
+	group := &singleflight.Group{}
+	opts := []OptReqParamsOption{WithRequestCoalescing(group), WithHeader("Authorization", "Bearer a")}
+	bodies := coalescedCalls(t, srv, true, opts, opts, opts)
+	if n := srv.calls.Load(); n != 1 {
+		t.Errorf("server got %d calls, want 1", n)
+	}
+	for i, b := range bodies {
+		if b != "Bearer a" {
+			t.Errorf("caller %d got %q", i, b)
+		}
+	}
+}
+
+func TestRequestCoalescingKeepsCredentialsApart(t *testing.T) {
+	group := &singleflight.Group{}
+	for name, second := range map[string]OptReqParamsOption{
+		"authorization": WithHeader("Authorization", "Bearer b"),
+		"range":         WithHeader("Range", "bytes=0-9"),
+		"accept":        WithAcceptHeader("text/plain"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := newBlockingServer(t)
+			first := []OptReqParamsOption{WithRequestCoalescing(group), WithHeader("Authorization", "Bearer a")}
+			bodies := coalescedCalls(t, srv, false, first, append([]OptReqParamsOption{second}, first[0]))
+			if n := srv.calls.Load(); n != 2 {
+				t.Errorf("server got %d calls, want 2", n)
+			}
+			if bodies[0] == bodies[1] {
+				t.Errorf("both callers got %q", bodies[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+// Compose returns one option applying all opts in given order, so preset option sets can be combined
+// like Compose(OrgDefaults, AuthOptions). options after it still override what it set
+func Compose(opts ...OptReqParamsOption) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		transparent(s)
+		for _, o := range opts {
+			applyOption(s, o)
+		}
+	}
+}
+
+// WithConditional applies opt only when condition is true, saves the if around appending options
+func WithConditional(condition bool, opt OptReqParamsOption) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		transparent(s)
+		if condition {
+			applyOption(s, opt)
+		}
+	}
+}
+
+// WithConditionalFunc is same as WithConditional but fn is called when the option is applied,
+// that is when the params are constructed, not when the option is created
+func WithConditionalFunc(fn func() bool, opt OptReqParamsOption) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		transparent(s)
+		if fn() {
+			applyOption(s, opt)
+		}
+	}
+}
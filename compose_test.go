@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestComposeOrder(t *testing.T) {
+	defaults := Compose(WithMethod(http.MethodPost), WithUserAgent("defaults"), WithHeader("X-Order", "1"))
+	extra := Compose(WithUserAgent("extra"), WithHeader("X-Order", "2"))
+	p := NewOptReqParams(Compose(defaults, extra), WithMethod(http.MethodPut), WithHeader("X-Order", "3"))
+
+	if p.httpMethod != http.MethodPut {
+		t.Errorf("method %s, want later WithMethod to override composed one", p.httpMethod)
+	}
+	if p.userAgent != "extra" {
+		t.Errorf("user agent %q, want one of later composed set", p.userAgent)
+	}
+	if got := p.headers.Values("X-Order"); !slices.Equal(got, []string{"1", "2", "3"}) {
+		t.Errorf("headers added in order %q", got)
+	}
+
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, Compose(defaults, extra), WithMethod(http.MethodPut))
+	if req, _ := srv.last(t); req.Method != http.MethodPut || req.UserAgent() != "extra" {
+		t.Errorf("server got %s with User-Agent %q", req.Method, req.UserAgent())
+	}
+}
+
+func TestComposeEmpty(t *testing.T) {
+	if p := NewOptReqParams(Compose()); p.httpMethod != http.MethodGet {
+		t.Errorf("empty Compose changed method to %s", p.httpMethod)
+	}
+}
+
+func TestConditional(t *testing.T) {
+	srv := newRecordingServer(t)
+	applied := 0
+	counting := func(s *OptReqParams) { applied++ }
+	for _, enabled := range []bool{true, false} {
+		mustCall(t, srv.URL, WithConditional(enabled, WithHeader("X-Debug", "1")), WithConditional(enabled, counting))
+		req, _ := srv.last(t)
+		if got := req.Header.Get("X-Debug") == "1"; got != enabled {
+			t.Errorf("condition %v: header sent %v", enabled, got)
+		}
+	}
+	if applied != 1 {
+		t.Errorf("inner option applied %d times, want only when condition was true", applied)
+	}
+}
+
+func TestConditionalFuncEvaluatedOnApply(t *testing.T) {
+	enabled := false
+	opt := WithConditionalFunc(func() bool { return enabled }, WithUserAgent("on"))
+	if p := NewOptReqParams(opt); p.userAgent != "" {
+		t.Error("option applied while condition was false")
+	}
+	enabled = true
+	if p := NewOptReqParams(opt); p.userAgent != "on" {
+		t.Error("option not applied after condition became true")
+	}
+}
+
+func TestConditionalOptionFoundByConflictsWith(t *testing.T) {
+	_, err := call(t, "http://example.invalid", WithUserAgent("a").ConflictsWith(WithAcceptLanguage("")),
+		WithConditional(true, WithAcceptLanguage("en")))
+	if !errors.Is(err, ErrConflictingOptions) {
+		t.Errorf("err = %v, want ErrConflictingOptions", err)
+	}
+}
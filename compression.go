@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+)
+
+// WithDisableCompression stops transport from asking for gzip and decompressing responses transparently,
+// for callers handling compression themselves
+func WithDisableCompression() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.disableCompression = true
+	}
+}
+
+// WithGzipBody gzip compresses request body and sets Content-Encoding: gzip
+func WithGzipBody() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.bodyEncoding = "gzip"
+	}
+}
+
+// WithBrotliBody brotli compresses request body and sets Content-Encoding: br
+func WithBrotliBody() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.bodyEncoding = "br"
+	}
+}
+
+// compressBody replaces body of req with its compressed copy if WithGzipBody or WithBrotliBody was given.
+// Compressed body is buffered so it can be sent again on retries
+func compressBody(req *http.Request, p *OptReqParams) error {
+	if p.bodyEncoding == "" || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch p.bodyEncoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	_, err := io.Copy(w, req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	b := buf.Bytes()
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.ContentLength = int64(len(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	req.Header.Set("Content-Encoding", p.bodyEncoding)
+	return nil
+}
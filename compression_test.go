@@ -0,0 +1,89 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestCompressedBody(t *testing.T) {
+	payload := strings.Repeat(`{"field":"value"},`, 200)
+	tests := []struct {
+		name     string
+		opt      OptReqParamsOption
+		encoding string
+		reader   func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", WithGzipBody(), "gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"brotli", WithBrotliBody(), "br", func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+	}
+	srv := newRecordingServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustCall(t, srv.URL, WithMethod(http.MethodPost), WithRawBody([]byte(payload)), tt.opt)
+			req, body := srv.last(t)
+			if ce := req.Header.Get("Content-Encoding"); ce != tt.encoding {
+				t.Errorf("Content-Encoding %q", ce)
+			}
+			if req.ContentLength != int64(len(body)) || len(body) >= len(payload) {
+				t.Errorf("Content-Length %d, body %d bytes, payload %d bytes", req.ContentLength, len(body), len(payload))
+			}
+			r, err := tt.reader(strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || string(got) != payload {
+				t.Errorf("decompressed body differs from payload, err %v", err)
+			}
+		})
+	}
+}
+
+func TestCompressedBodyRetried(t *testing.T) {
+	var bodies []string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("attempt %d: %v", len(bodies), err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		if bodies = append(bodies, string(b)); len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	mustCall(t, srv.URL, WithMethod(http.MethodPost), WithRawBody([]byte("again")), WithGzipBody(), WithMaxRetries(1),
+		WithRetryBackoff(time.Millisecond, time.Millisecond, 2))
+	if len(bodies) != 2 || bodies[0] != "again" || bodies[1] != "again" {
+		t.Errorf("attempts got %q", bodies)
+	}
+}
+
+func TestDisableCompression(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if ae := r.Header.Get("Accept-Encoding"); ae != "" {
+			t.Errorf("Accept-Encoding %q with compression disabled", ae)
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = io.WriteString(zw, "compressed")
+		_ = zw.Close()
+	})
+	res, body := mustCall(t, srv.URL, WithDisableCompression())
+	if res.Header.Get("Content-Encoding") != "gzip" || body == "compressed" {
+		t.Fatalf("response was decompressed by transport")
+	}
+	zr, err := gzip.NewReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != "compressed" {
+		t.Errorf("got %q", got)
+	}
+}
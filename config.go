@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// jsonOptions maps keys known by LoadOptionsFromJSON to functions turning their value into an option
+var jsonOptions = map[string]func(json.RawMessage) (OptReqParamsOption, error){
+	"http_method":   jsonOption(WithMethod),
+	"accept_header": jsonOption(WithAcceptHeader),
+	"content_type":  jsonOption(WithContentType),
+	"user_agent":    jsonOption(WithUserAgent),
+	"base_url":      jsonOption(WithBaseURL),
+	"proxy_url":     jsonOption(WithProxy),
+	"max_retries":   jsonOption(WithMaxRetries),
+	"headers":       jsonOption(WithHeaders),
+	"query_params":  jsonOption(WithQueryParam),
+	"timeout_ms": jsonOption(func(ms int64) OptReqParamsOption {
+		return WithTimeout(time.Duration(ms) * time.Millisecond)
+	}),
+	"insecure_skip_verify": jsonOption(func(skip bool) OptReqParamsOption {
+		return WithConditional(skip, WithTLSInsecureSkipVerify())
+	}),
+}
+
+// LoadOptionsFromJSON reads a JSON object from r and returns options for the keys it knows, so client
+// settings can come from a config file. Known keys are http_method, accept_header, content_type,
+// user_agent, base_url, proxy_url, timeout_ms, max_retries, insecure_skip_verify, headers and
+// query_params, last two are objects of strings. Unknown keys are logged and skipped
+func LoadOptionsFromJSON(r io.Reader) ([]OptReqParamsOption, error) {
+	var cfg map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: json config: %v", ErrInvalidOption, err)
+	}
+
+	var opts []OptReqParamsOption
+	for _, key := range slices.Sorted(maps.Keys(cfg)) { // sorted so result doesn't depend on map order
+		fn, ok := jsonOptions[key]
+		if !ok {
+			log.Printf("LoadOptionsFromJSON: ignoring unknown key %q", key)
+			continue
+		}
+		o, err := fn(cfg[key])
+		if err != nil {
+			return nil, fmt.Errorf("%w: json config key %q: %v", ErrInvalidOption, key, err)
+		}
+		opts = append(opts, o)
+	}
+	return opts, nil
+}
+
+// jsonOption adapts option constructor with one argument so it can be fed with a raw JSON value
+func jsonOption[T any](with func(T) OptReqParamsOption) func(json.RawMessage) (OptReqParamsOption, error) {
+	return func(raw json.RawMessage) (OptReqParamsOption, error) {
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return with(v), nil
+	}
+}
+
+// envOptions maps variable names known by LoadOptionsFromEnv, without prefix, to functions turning
+// their value into an option
+var envOptions = map[string]func(string) (OptReqParamsOption, error){
+	"HTTP_METHOD":   envOption(WithMethod),
+	"ACCEPT_HEADER": envOption(WithAcceptHeader),
+	"CONTENT_TYPE":  envOption(WithContentType),
+	"USER_AGENT":    envOption(WithUserAgent),
+	"BASE_URL":      envOption(WithBaseURL),
+	"PROXY_URL":     envOption(WithProxy),
+	"MAX_RETRIES": func(v string) (OptReqParamsOption, error) {
+		n, err := strconv.Atoi(v)
+		return WithMaxRetries(n), err
+	},
+	"TIMEOUT_MS": func(v string) (OptReqParamsOption, error) {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		return WithTimeout(time.Duration(ms) * time.Millisecond), err
+	},
+	"INSECURE_SKIP_VERIFY": func(v string) (OptReqParamsOption, error) {
+		skip, err := strconv.ParseBool(v)
+		return WithConditional(skip, WithTLSInsecureSkipVerify()), err
+	},
+}
+
+// LoadOptionsFromEnv returns options for environment variables named prefix followed by an underscore and
+// one of
+//
+//	HTTP_METHOD           see WithMethod
+//	ACCEPT_HEADER         see WithAcceptHeader
+//	CONTENT_TYPE          see WithContentType
+//	USER_AGENT            see WithUserAgent
+//	BASE_URL              see WithBaseURL
+//	PROXY_URL             see WithProxy
+//	TIMEOUT_MS            see WithTimeout, in milliseconds
+//	MAX_RETRIES           see WithMaxRetries
+//	INSECURE_SKIP_VERIFY  see WithTLSInsecureSkipVerify, true or false
+//
+// like MYAPI_TIMEOUT_MS for prefix MYAPI. Variables which are not set give no option so defaults are kept.
+// A value which can't be parsed is returned by CustomHTTPRequest without making any call
+func LoadOptionsFromEnv(prefix string) []OptReqParamsOption {
+	var opts []OptReqParamsOption
+	for _, name := range slices.Sorted(maps.Keys(envOptions)) {
+		v, ok := os.LookupEnv(prefix + "_" + name)
+		if !ok {
+			continue
+		}
+		o, err := envOptions[name](v)
+		if err != nil {
+			o = withOptErr(fmt.Errorf("%w: env %s_%s: %v", ErrInvalidOption, prefix, name, err))
+		}
+		opts = append(opts, o)
+	}
+	return opts
+}
+
+// envOption adapts option constructor taking a string so it fits envOptions
+func envOption(with func(string) OptReqParamsOption) func(string) (OptReqParamsOption, error) {
+	return func(v string) (OptReqParamsOption, error) {
+		return with(v), nil
+	}
+}
+
+// withOptErr returns option which only records err for CustomHTTPRequest
+func withOptErr(err error) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		transparent(s)
+		s.optErrs = append(s.optErrs, err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadOptionsFromJSON(t *testing.T) {
+	const cfg = `{
+		"http_method": "PATCH",
+		"accept_header": "application/xml",
+		"content_type": "text/plain",
+		"user_agent": "cfg-client",
+		"base_url": "https://api.example.com/v2/",
+		"proxy_url": "http://proxy.example.com:3128",
+		"timeout_ms": 1500,
+		"max_retries": 4,
+		"insecure_skip_verify": true,
+		"headers": {"X-Team": "payments"},
+		"query_params": {"region": "eu"},
+		"unknown": 1
+	}`
+	opts, err := LoadOptionsFromJSON(strings.NewReader(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewOptReqParams(opts...)
+	for _, c := range []struct {
+		name      string
+		got, want any
+	}{
+		{"method", p.httpMethod, http.MethodPatch},
+		{"accept", p.acceptHeader, "application/xml"},
+		{"content type", p.contentType, "text/plain"},
+		{"user agent", p.userAgent, "cfg-client"},
+		{"base url", p.baseURL.String(), "https://api.example.com/v2/"},
+		{"proxy", p.proxyKey, "http://proxy.example.com:3128"},
+		{"timeout", p.timeout, 1500 * time.Millisecond},
+		{"max retries", p.maxRetries, 4},
+		{"insecure", p.tlsConfig != nil && p.tlsConfig.InsecureSkipVerify, true},
+		{"header", p.headers.Get("X-Team"), "payments"},
+		{"query", p.queryParam.Get("region"), "eu"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadOptionsFromJSONRequest(t *testing.T) {
+	srv := newRecordingServer(t)
+	opts, err := LoadOptionsFromJSON(strings.NewReader(`{"base_url": "` + srv.URL + `/v1/", "http_method": "DELETE",
+		"headers": {"X-Team": "payments"}, "query_params": {"region": "eu"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCall(t, "items/1", opts...)
+	req, _ := srv.last(t)
+	if req.Method != http.MethodDelete || req.URL.String() != "/v1/items/1?region=eu" || req.Header.Get("X-Team") != "payments" {
+		t.Errorf("server got %s %s %v", req.Method, req.URL, req.Header)
+	}
+}
+
+func TestLoadOptionsFromJSONErrors(t *testing.T) {
+	for _, cfg := range []string{`not json`, `{"max_retries": "three"}`, `{"headers": ["a"]}`} {
+		if _, err := LoadOptionsFromJSON(strings.NewReader(cfg)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: err = %v, want ErrInvalidOption", cfg, err)
+		}
+	}
+	opts, err := LoadOptionsFromJSON(strings.NewReader(`{"base_url": "relative/"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := call(t, "items", opts...); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("bad base url: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestLoadOptionsFromEnv(t *testing.T) {
+	t.Setenv("MYAPI_HTTP_METHOD", "POST")
+	t.Setenv("MYAPI_TIMEOUT_MS", "250")
+	t.Setenv("MYAPI_INSECURE_SKIP_VERIFY", "false")
+	p := NewOptReqParams(LoadOptionsFromEnv("MYAPI")...)
+	if p.httpMethod != http.MethodPost || p.timeout != 250*time.Millisecond || p.tlsConfig != nil {
+		t.Errorf("params method %s timeout %v tls %v", p.httpMethod, p.timeout, p.tlsConfig)
+	}
+
+	t.Setenv("MYAPI_MAX_RETRIES", "many")
+	if _, err := call(t, "http://example.invalid", LoadOptionsFromEnv("MYAPI")...); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+)
+
+// WithCookieJar makes the client store cookies from Set-Cookie headers in jar and send them on later requests
+// made with same params, for session based apis
+func WithCookieJar(jar http.CookieJar) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.cookieJar = jar
+	}
+}
+
+// WithMemoryCookieJar is WithCookieJar with a new in memory jar from net/http/cookiejar, the jar is shared
+// by every params made with the returned option
+func WithMemoryCookieJar() OptReqParamsOption {
+	jar, _ := cookiejar.New(nil) // never fails without options
+	return withKind("WithMemoryCookieJar", WithCookieJar(jar))
+}
+
+// WithCookies attaches cookies to requests made with these params, unlike a jar nothing is remembered
+// from responses. Multiple calls add more cookies
+func WithCookies(cookies ...*http.Cookie) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.cookies = append(s.cookies, cookies...)
+	}
+}
+
+// applyCookies adds cookies from WithCookies to req
+func applyCookies(req *http.Request, p *OptReqParams) {
+	for _, c := range p.cookies {
+		req.AddCookie(c)
+	}
+}
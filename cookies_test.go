@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+)
+
+// newSessionServer sets a session cookie on /login and on every other path answers with the cookie it got
+func newSessionServer(t *testing.T) *countingServer {
+	return newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+			return
+		}
+		c, err := r.Cookie("session")
+		if err != nil {
+			_, _ = io.WriteString(w, "none")
+			return
+		}
+		_, _ = io.WriteString(w, c.Value)
+	})
+}
+
+func TestCookieJarReplaysLoginCookie(t *testing.T) {
+	srv := newSessionServer(t)
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCall(t, srv.URL+"/login", WithMethod(http.MethodPost), WithCookieJar(jar))
+	for range 2 {
+		if _, body := mustCall(t, srv.URL+"/api", WithCookieJar(jar)); body != "s3cr3t" {
+			t.Errorf("call after login sent session %q", body)
+		}
+	}
+	if _, body := mustCall(t, srv.URL+"/api"); body != "none" {
+		t.Errorf("call without jar sent session %q", body)
+	}
+}
+
+func TestMemoryCookieJarSharedByOption(t *testing.T) {
+	srv := newSessionServer(t)
+	jar := WithMemoryCookieJar()
+	mustCall(t, srv.URL+"/login", jar)
+	if _, body := mustCall(t, srv.URL+"/api", jar); body != "s3cr3t" {
+		t.Errorf("call with same jar option sent session %q", body)
+	}
+}
+
+func TestCookiesCompose(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL,
+		WithCookies(&http.Cookie{Name: "a", Value: "1"}, &http.Cookie{Name: "b", Value: "2"}),
+		WithCookies(&http.Cookie{Name: "c", Value: "3"}))
+	req, _ := srv.last(t)
+	got := map[string]string{}
+	for _, c := range req.Cookies() {
+		got[c.Name] = c.Value
+	}
+	if len(got) != 3 || got["a"] != "1" || got["b"] != "2" || got["c"] != "3" {
+		t.Errorf("server got cookies %v, want a, b and c", got)
+	}
+}
+
+func TestCookiesNotRemembered(t *testing.T) {
+	srv := newSessionServer(t)
+	opt := WithCookies(&http.Cookie{Name: "other", Value: "x"})
+	mustCall(t, srv.URL+"/login", opt)
+	if _, body := mustCall(t, srv.URL+"/api", opt); body != "none" {
+		t.Errorf("cookie from response was sent back: %q", body)
+	}
+}
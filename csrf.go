@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DefaultCSRFHeader is used by CSRF token options when no header name is given
+const DefaultCSRFHeader = "X-CSRF-Token"
+
+// WithCSRFToken sends token in headerName for endpoints protected against cross site request forgery.
+// empty headerName means DefaultCSRFHeader
+func WithCSRFToken(token, headerName string) OptReqParamsOption {
+	if headerName == "" {
+		headerName = DefaultCSRFHeader
+	}
+	return func(s *OptReqParams) {
+		if s.headers == nil {
+			s.headers = make(http.Header)
+		}
+		s.headers.Set(headerName, token)
+	}
+}
+
+// WithCSRFTokenFromResponse sends value of cookie cookieName set by resp, like the one of a login page,
+// as CSRF token in headerName. Missing cookie is returned by CustomHTTPRequest without making any call
+func WithCSRFTokenFromResponse(resp *http.Response, cookieName, headerName string) OptReqParamsOption {
+	var token string
+	found := false
+	if resp != nil {
+		for _, c := range resp.Cookies() {
+			if c.Name == cookieName {
+				token, found = c.Value, true
+				break
+			}
+		}
+	}
+	if !found {
+		return withOptErr(fmt.Errorf("%w: csrf cookie %q not in response", ErrInvalidOption, cookieName))
+	}
+	return withKind("WithCSRFTokenFromResponse", WithCSRFToken(token, headerName))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCSRFToken(t *testing.T) {
+	srv := newRecordingServer(t)
+	for _, tc := range []struct {
+		header, sent string
+	}{
+		{"", DefaultCSRFHeader},
+		{"X-XSRF-Token", "X-XSRF-Token"},
+	} {
+		_, _ = mustCall(t, srv.URL, WithMethod(http.MethodPost), WithCSRFToken("csrf-value", tc.header))
+		if r, _ := srv.last(t); r.Header.Get(tc.sent) != "csrf-value" {
+			t.Errorf("header %q: server got %q, want %q", tc.sent, r.Header.Get(tc.sent), "csrf-value")
+		}
+	}
+}
+
+func TestCSRFTokenFromResponse(t *testing.T) {
+	form := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s-1"})
+		http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "from-cookie"})
+	})
+	page, _ := mustCall(t, form.URL)
+
+	srv := newRecordingServer(t)
+	_, _ = mustCall(t, srv.URL, WithMethod(http.MethodPost), WithCSRFTokenFromResponse(page, "csrftoken", "X-CSRFToken"))
+	if r, _ := srv.last(t); r.Header.Get("X-CSRFToken") != "from-cookie" {
+		t.Errorf("server got X-CSRFToken %q, want %q", r.Header.Get("X-CSRFToken"), "from-cookie")
+	}
+
+	for name, resp := range map[string]*http.Response{"missing cookie": page, "nil response": nil} {
+		_, err := call(t, srv.URL, WithCSRFTokenFromResponse(resp, "xsrf", ""))
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: error %v, want ErrInvalidOption", name, err)
+		}
+	}
+	if n := srv.count(); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WithDeadline sets an absolute deadline on the request context, for deadlines coming from outside like
+// one of an incoming rpc. If WithTimeout is also set, whichever ends earlier is used
+func WithDeadline(t time.Time) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.deadline = t
+	}
+}
+
+// deadlineContext derives ctx with deadline of p if there is one, returned cancel must always be called
+func deadlineContext(ctx context.Context, p *OptReqParams) (context.Context, context.CancelFunc) {
+	if p.deadline.IsZero() {
+		return ctx, func() {}
+	}
+	d := p.deadline
+	if p.timeout > 0 {
+		if t := time.Now().Add(p.timeout); t.Before(d) {
+			d = t
+		}
+	}
+	return context.WithDeadline(ctx, d)
+}
+
+// cancelOnClose ties cancel to res body, so context stays alive while caller reads the body and is
+// released once body is closed. Without response cancel is called right away
+func cancelOnClose(res *http.Response, cancel context.CancelFunc) {
+	if res == nil {
+		cancel()
+		return
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
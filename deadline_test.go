@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	srv := newCountingServer(t, false, slowHandler(2*time.Second))
+	for _, tc := range []struct {
+		name string
+		opts []OptReqParamsOption
+	}{
+		{"deadline", []OptReqParamsOption{WithDeadline(time.Now().Add(50 * time.Millisecond))}},
+		{"timeout earlier", []OptReqParamsOption{WithDeadline(time.Now().Add(time.Hour)), WithTimeout(50 * time.Millisecond)}},
+		{"deadline earlier", []OptReqParamsOption{WithDeadline(time.Now().Add(50 * time.Millisecond)), WithTimeout(time.Hour)}},
+	} {
+		start := time.Now()
+		_, err := call(t, srv.URL, tc.opts...)
+		if err == nil {
+			t.Errorf("%s: want error", tc.name)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s: call took %v, want it cut at 50ms", tc.name, d)
+		}
+	}
+
+	_, err := call(t, srv.URL, WithDeadline(time.Now().Add(-time.Second)))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("past deadline: error %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestDeadlineKeepsBodyReadable(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("headers"))
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(" and body"))
+	})
+	// context of the call must stay alive after CustomHTTPRequest returned, until body is closed
+	if _, body := mustCall(t, srv.URL, WithDeadline(time.Now().Add(time.Minute))); body != "headers and body" {
+		t.Errorf("body %q", body)
+	}
+}
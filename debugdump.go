@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+)
+
+// redactedValue replaces values of sensitive headers in dumps
+const redactedValue = "[REDACTED]"
+
+// maxDumpBodySize is how much of a response body goes into debug dump, rest of it is only counted
+const maxDumpBodySize = 64 << 10
+
+// DefaultSensitiveHeaders are always redacted in debug dumps unless WithDebugDumpSensitive is given
+var DefaultSensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "Proxy-Authorization"}
+
+// WithDebugDump writes every request and response to w as they go over the wire, like curl -v.
+// Each dump is enclosed in delimiter lines so many of them in the same writer can be told apart.
+// Response body is not buffered, its first 64KiB are dumped as caller reads it, once it is read to the end or
+// closed. Event streams are dumped without body. Credentials like Authorization and api key of
+// WithAPIKeyInHeader or WithAPIKeyInQuery are redacted unless WithDebugDumpSensitive is also given,
+// see DefaultSensitiveHeaders and WithSensitiveHeaderRedaction
+func WithDebugDump(w io.Writer) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.debugDump = w
+	}
+}
+
+// WithDebugDumpSensitive keeps sensitive header values in debug dumps, never use it in production
+func WithDebugDumpSensitive() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.debugDumpSensitive = true
+	}
+}
+
+// WithSensitiveHeaderRedaction adds headers to redact in debug dumps on top of DefaultSensitiveHeaders,
+// for custom credentials like X-Auth-Token. Multiple calls accumulate
+func WithSensitiveHeaderRedaction(headers ...string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.sensitiveHeaders = append(s.sensitiveHeaders, headers...)
+	}
+}
+
+// WithRequestBodySanitizer passes request body through fn before it is written to debug dump, to keep passwords
+// and other personal data out of it. Body sent is not changed. Multiple calls compose and run in order,
+// see JSONFieldSanitizer for a ready to use one
+func WithRequestBodySanitizer(fn func(body []byte) []byte) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.bodySanitizers = append(s.bodySanitizers, fn)
+	}
+}
+
+// JSONFieldSanitizer returns body sanitizer which replaces values of given keys anywhere in a JSON body with
+// [REDACTED]. Body which is not JSON is returned as it is. Keys of sanitized body come out sorted
+func JSONFieldSanitizer(fields ...string) func(body []byte) []byte {
+	redact := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		redact[f] = true
+	}
+	return func(body []byte) []byte {
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return body
+		}
+		b, err := json.Marshal(redactJSON(v, redact))
+		if err != nil {
+			return body
+		}
+		return b
+	}
+}
+
+// redactJSON replaces values of redact keys in decoded JSON v, in nested objects and arrays too
+func redactJSON(v any, redact map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if redact[k] {
+				v[k] = redactedValue
+			} else {
+				v[k] = redactJSON(child, redact)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactJSON(child, redact)
+		}
+	}
+	return v
+}
+
+// dumpRequest writes req to debug dump writer of p, req body is left intact
+func dumpRequest(req *http.Request, p *OptReqParams) {
+	if p.debugDump == nil {
+		return
+	}
+
+	// dump a copy so redaction and reading the body don't touch the real request
+	r := req.Clone(req.Context())
+	r.Header = redactHeaders(req.Header, p)
+	if p.apiKeyInQuery && !p.debugDumpSensitive {
+		q := r.URL.Query()
+		q.Set(p.apiKeyName, redactedValue)
+		r.URL.RawQuery = q.Encode()
+	}
+	withBody := req.Body == nil || req.GetBody != nil
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err == nil {
+			r.Body = body
+		} else {
+			withBody = false
+		}
+	}
+	if withBody && len(p.bodySanitizers) > 0 && r.Body != nil {
+		if b, err := io.ReadAll(r.Body); err == nil {
+			for _, fn := range p.bodySanitizers {
+				b = fn(b)
+			}
+			r.Body, r.ContentLength = io.NopCloser(bytes.NewReader(b)), int64(len(b))
+		} else {
+			withBody = false
+		}
+	}
+
+	b, err := httputil.DumpRequestOut(r, withBody)
+	writeDump(p.debugDump, fmt.Sprintf("request %s %s", r.Method, r.URL), b, err)
+}
+
+// dumpResponse writes status line and headers of res to debug dump writer of p right away, body is dumped
+// by dumpBody while caller reads it so streamed and large bodies are never buffered
+func dumpResponse(res *http.Response, p *OptReqParams) {
+	if p.debugDump == nil {
+		return
+	}
+
+	header := res.Header
+	res.Header = redactHeaders(header, p)
+	b, err := httputil.DumpResponse(res, false)
+	res.Header = header
+	title := fmt.Sprintf("response %s", res.Status)
+	writeDump(p.debugDump, title, b, err)
+	if res.Body == nil || res.Body == http.NoBody || isEventStream(res) {
+		return
+	}
+	res.Body = &dumpBody{ReadCloser: res.Body, w: p.debugDump, title: title + " body"}
+}
+
+// dumpBody keeps first maxDumpBodySize bytes read through it and dumps them at EOF or close
+type dumpBody struct {
+	io.ReadCloser
+	w      io.Writer
+	title  string
+	prefix []byte
+	total  int64
+	done   bool
+}
+
+func (b *dumpBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if keep := min(n, maxDumpBodySize-len(b.prefix)); keep > 0 {
+		b.prefix = append(b.prefix, p[:keep]...)
+	}
+	b.total += int64(n)
+	if err == io.EOF {
+		b.dump()
+	}
+	return n, err
+}
+
+func (b *dumpBody) Close() error {
+	b.dump()
+	return b.ReadCloser.Close()
+}
+
+// dump writes kept prefix once, noting how much of the body was left out
+func (b *dumpBody) dump() {
+	if b.done {
+		return
+	}
+	b.done = true
+	out := b.prefix
+	if left := b.total - int64(len(b.prefix)); left > 0 {
+		out = fmt.Appendf(slices.Clip(out), "\n[%d more bytes]", left)
+	}
+	writeDump(b.w, b.title, out, nil)
+}
+
+// isEventStream tells whether res is a server-sent event stream, which has no end to wait for
+func isEventStream(res *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// redactHeaders returns copy of h with sensitive values replaced, or h itself when they should be kept
+func redactHeaders(h http.Header, p *OptReqParams) http.Header {
+	if p.debugDumpSensitive {
+		return h
+	}
+	c := h.Clone()
+	for _, name := range sensitiveHeaderNames(p) {
+		if _, ok := c[http.CanonicalHeaderKey(name)]; ok {
+			c.Set(name, redactedValue)
+		}
+	}
+	return c
+}
+
+// sensitiveHeaderNames returns headers of p holding credentials: DefaultSensitiveHeaders, ones given to
+// WithSensitiveHeaderRedaction and header of WithAPIKeyInHeader
+func sensitiveHeaderNames(p *OptReqParams) []string {
+	names := slices.Concat(DefaultSensitiveHeaders, p.sensitiveHeaders)
+	if p.apiKey != "" && !p.apiKeyInQuery {
+		names = append(names, p.apiKeyName)
+	}
+	return names
+}
+
+// writeDump writes b enclosed in delimiter lines
+func writeDump(w io.Writer, title string, b []byte, err error) {
+	if err != nil {
+		fmt.Fprintf(w, "===== %s: dump failed: %v =====\n", title, err)
+		return
+	}
+	fmt.Fprintf(w, "===== begin %s =====\n%s\n===== end %s =====\n", title, b, title)
+}
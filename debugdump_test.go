@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugDump(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		_, _ = w.Write([]byte("response body"))
+	})
+	var dump bytes.Buffer
+	_, body := mustCall(t, srv.URL, WithDebugDump(&dump), WithMethod(http.MethodPost),
+		WithRawBody([]byte("request body")), WithHeader("X-Auth-Token", "custom-secret"),
+		WithSensitiveHeaderRedaction("X-Auth-Token"))
+	if body != "response body" {
+		t.Errorf("caller got body %q", body)
+	}
+	out := dump.String()
+	for _, want := range []string{"===== begin request POST", "request body", "===== begin response 200 OK",
+		"response body", "X-Auth-Token: " + redactedValue, "Set-Cookie: " + redactedValue} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump is missing %q:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"custom-secret", "cookie-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("dump leaks %s:\n%s", secret, out)
+		}
+	}
+}
+
+func TestDebugDumpRedactsAPIKeyInQuery(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("key"); got != "query-secret" {
+			t.Errorf("server got key %q", got)
+		}
+	})
+	var dump bytes.Buffer
+	p := NewOptReqParams(WithAPIKeyInQuery("query-secret", "key"), WithDebugDump(&dump))
+	res, err := CustomHTTPRequest(t.Context(), srv.URL, "", "", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	if strings.Contains(dump.String(), "query-secret") {
+		t.Errorf("dump leaks api key:\n%s", dump.String())
+	}
+	if !strings.Contains(dump.String(), "key=%5BREDACTED%5D") {
+		t.Errorf("dump doesn't show redacted key:\n%s", dump.String())
+	}
+}
+
+func TestDebugDumpDoesntBufferStreams(t *testing.T) {
+	release := make(chan struct{})
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		<-release // stream stays open until the caller got the response
+	})
+	defer close(release)
+
+	var dump bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := call(t, srv.URL, WithDebugDump(&dump))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = res.Body.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CustomHTTPRequest waited for end of the stream")
+	}
+}
+
+func TestDebugDumpLargeBodyTruncated(t *testing.T) {
+	large := strings.Repeat("x", maxDumpBodySize+10)
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(large))
+	})
+	var dump bytes.Buffer
+	_, body := mustCall(t, srv.URL, WithDebugDump(&dump))
+	if body != large {
+		t.Errorf("caller got %d bytes, want %d", len(body), len(large))
+	}
+	if !strings.Contains(dump.String(), "[10 more bytes]") {
+		t.Error("dump of large body is not truncated")
+	}
+}
+
+func TestDebugDumpRedactsSensitiveHeaders(t *testing.T) {
+	stubLogin(t, "login-secret")
+	srv := newRecordingServer(t)
+	for name, tc := range map[string]struct {
+		opts   []OptReqParamsOption
+		secret string
+	}{
+		"login token": {secret: "login-secret"},
+		"bearer":      {opts: []OptReqParamsOption{WithBearerToken("bearer-secret")}, secret: "bearer-secret"},
+		"basic":       {opts: []OptReqParamsOption{WithBasicAuth("user", "basic-secret")}, secret: "dXNlcjpiYXNpYy1zZWNyZXQ="},
+		"cookie": {opts: []OptReqParamsOption{WithNoAuth(), WithCookies(&http.Cookie{Name: "id", Value: "cookie-secret"})},
+			secret: "cookie-secret"},
+		"api key": {opts: []OptReqParamsOption{WithHeader("X-Api-Key", "key-secret")}, secret: "key-secret"},
+	} {
+		var dump bytes.Buffer
+		res, err := callWithAuth(t, srv.URL, append(tc.opts, WithDebugDump(&dump))...)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		readBody(t, res)
+		if r, _ := srv.last(t); !strings.Contains(fmt.Sprint(r.Header), tc.secret) {
+			t.Errorf("%s: server didn't get %q, headers %v", name, tc.secret, r.Header)
+		}
+		if strings.Contains(dump.String(), tc.secret) {
+			t.Errorf("%s: dump leaks %q:\n%s", name, tc.secret, dump.String())
+		}
+		if !strings.Contains(dump.String(), redactedValue) {
+			t.Errorf("%s: dump has no %s:\n%s", name, redactedValue, dump.String())
+		}
+	}
+
+	// headers are sent as they are, only the dump is redacted
+	var dump bytes.Buffer
+	res, err := callWithAuth(t, srv.URL, WithBearerToken("bearer-secret"), WithDebugDump(&dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	if r, _ := srv.last(t); r.Header.Get("Authorization") != "Bearer bearer-secret" {
+		t.Errorf("server got Authorization %q", r.Header.Get("Authorization"))
+	}
+
+	dump.Reset()
+	res, err = callWithAuth(t, srv.URL, WithBearerToken("bearer-secret"), WithDebugDump(&dump), WithDebugDumpSensitive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	if !strings.Contains(dump.String(), "Authorization: Bearer bearer-secret") {
+		t.Errorf("WithDebugDumpSensitive dump has no Authorization:\n%s", dump.String())
+	}
+}
+
+func TestDebugDumpBodySanitizer(t *testing.T) {
+	srv := newRecordingServer(t)
+	var dump bytes.Buffer
+	_, _ = mustCall(t, srv.URL, WithMethod(http.MethodPost), WithRawBody([]byte(`{"password":"secret"}`)),
+		WithDebugDump(&dump), WithRequestBodySanitizer(JSONFieldSanitizer("password")))
+	if !strings.Contains(dump.String(), `{"password":"[REDACTED]"}`) {
+		t.Errorf("dump has no sanitized body:\n%s", dump.String())
+	}
+	if strings.Contains(dump.String(), "secret") {
+		t.Errorf("dump leaks password:\n%s", dump.String())
+	}
+	if _, body := srv.last(t); body != `{"password":"secret"}` {
+		t.Errorf("server got %q, sent body must not be sanitized", body)
+	}
+}
+
+func TestJSONFieldSanitizer(t *testing.T) {
+	sanitize := JSONFieldSanitizer("password", "ssn")
+	for _, tc := range []struct{ in, want string }{
+		{`{"password":"secret"}`, `{"password":"[REDACTED]"}`},
+		{`{"user":{"name":"a","ssn":"123"},"list":[{"password":1}]}`,
+			`{"list":[{"password":"[REDACTED]"}],"user":{"name":"a","ssn":"[REDACTED]"}}`},
+		{`{"other":"kept"}`, `{"other":"kept"}`},
+		{`password=secret`, `password=secret`}, // not json, left alone
+	} {
+		if got := string(sanitize([]byte(tc.in))); got != tc.want {
+			t.Errorf("sanitize(%s) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestDebugDumpBodySanitizersCompose(t *testing.T) {
+	srv := newRecordingServer(t)
+	var dump bytes.Buffer
+	upper := func(b []byte) []byte { return bytes.ToUpper(b) }
+	_, _ = mustCall(t, srv.URL, WithMethod(http.MethodPost), WithRawBody([]byte(`{"password":"secret","name":"x"}`)),
+		WithDebugDump(&dump), WithRequestBodySanitizer(JSONFieldSanitizer("password")), WithRequestBodySanitizer(upper))
+	if !strings.Contains(dump.String(), `{"NAME":"X","PASSWORD":"[REDACTED]"}`) {
+		t.Errorf("sanitizers didn't run in order:\n%s", dump.String())
+	}
+}
+
+func TestDebugDumpRedactsAPIKeyInCustomHeader(t *testing.T) {
+	srv := newRecordingServer(t)
+	var dump bytes.Buffer
+	res, err := callWithAuth(t, srv.URL, WithAPIKeyInHeader("sekrit-key", "X-Service-Token"), WithDebugDump(&dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	if strings.Contains(dump.String(), "sekrit-key") {
+		t.Errorf("dump leaks api key:\n%s", dump.String())
+	}
+	if !strings.Contains(dump.String(), "X-Service-Token: "+redactedValue) {
+		t.Errorf("dump doesn't show redacted X-Service-Token:\n%s", dump.String())
+	}
+	if r, _ := srv.last(t); r.Header.Get("X-Service-Token") != "sekrit-key" {
+		t.Errorf("server got X-Service-Token %q", r.Header.Get("X-Service-Token"))
+	}
+}
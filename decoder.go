@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+)
+
+// ResponseDecoder deserializes response body into v
+type ResponseDecoder interface {
+	Decode(r io.Reader, v any) error
+}
+
+// WithResponseDecoder sets decoder used by CustomHTTPRequestInto, default is NewJSONDecoder
+func WithResponseDecoder(dec ResponseDecoder) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.decoder = dec
+	}
+}
+
+// CustomHTTPRequestInto is same as CustomHTTPRequest but also decodes response body into target
+// with decoder from WithResponseDecoder. Body is consumed and closed, response is returned for status and headers
+func CustomHTTPRequestInto(ctx context.Context, url, email, passwd string, p *OptReqParams, target any) (*http.Response, error) {
+	res, err := CustomHTTPRequest(ctx, url, email, passwd, p)
+	if err != nil || res == nil {
+		return nil, err // nil response is an empty long poll, nothing to decode
+	}
+	defer res.Body.Close()
+
+	dec := p.decoder
+	if dec == nil {
+		dec = NewJSONDecoder()
+	}
+	if err := dec.Decode(res.Body, target); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// NewJSONDecoder returns a ResponseDecoder using encoding/json
+func NewJSONDecoder() ResponseDecoder {
+	return jsonDecoder{}
+}
+
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// NewXMLDecoder returns a ResponseDecoder using encoding/xml
+func NewXMLDecoder() ResponseDecoder {
+	return xmlDecoder{}
+}
+
+type xmlDecoder struct{}
+
+func (xmlDecoder) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// NewGobDecoder returns a ResponseDecoder using encoding/gob
+func NewGobDecoder() ResponseDecoder {
+	return gobDecoder{}
+}
+
+type gobDecoder struct{}
+
+func (gobDecoder) Decode(r io.Reader, v any) error {
+	return gob.NewDecoder(r).Decode(v)
+}
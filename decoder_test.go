@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type decodedItem struct {
+	Name  string `json:"name" xml:"name"`
+	Count int    `json:"count" xml:"count"`
+}
+
+func TestCustomHTTPRequestInto(t *testing.T) {
+	want := decodedItem{Name: "widget", Count: 3}
+	jsonBody, _ := json.Marshal(want)
+	xmlBody, _ := xml.Marshal(want)
+	var gobBody bytes.Buffer
+	_ = gob.NewEncoder(&gobBody).Encode(want)
+
+	for _, tc := range []struct {
+		name string
+		body []byte
+		opts []OptReqParamsOption
+	}{
+		{"json by default", jsonBody, nil},
+		{"json", jsonBody, []OptReqParamsOption{WithResponseDecoder(NewJSONDecoder())}},
+		{"xml", xmlBody, []OptReqParamsOption{WithResponseDecoder(NewXMLDecoder())}},
+		{"gob", gobBody.Bytes(), []OptReqParamsOption{WithResponseDecoder(NewGobDecoder())}},
+	} {
+		srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(tc.body)
+		})
+		var got decodedItem
+		p := NewOptReqParams(append([]OptReqParamsOption{WithNoAuth()}, tc.opts...)...)
+		res, err := CustomHTTPRequestInto(context.Background(), srv.URL, "", "", p, &got)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if res.StatusCode != http.StatusOK || got != want {
+			t.Errorf("%s: got %d %+v, want 200 %+v", tc.name, res.StatusCode, got, want)
+		}
+	}
+}
+
+func TestCustomHTTPRequestIntoDecodeError(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<not json>"))
+	})
+	var got decodedItem
+	res, err := CustomHTTPRequestInto(context.Background(), srv.URL, "", "", NewOptReqParams(WithNoAuth()), &got)
+	var se *json.SyntaxError
+	if !errors.As(err, &se) {
+		t.Errorf("error %v, want json syntax error", err)
+	}
+	if res == nil || res.StatusCode != http.StatusOK {
+		t.Errorf("response %v, want it returned along with the decode error", res)
+	}
+}
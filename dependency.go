@@ -0,0 +1,139 @@
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// optionKind identifies an option for ConflictsWith, like WithBasicAuth. options are funcs and can't be
+// compared, so each option applied through applyOption records its kind: name of the constructor which made
+// it, or an explicit one given with withKind or NamedOption. Wrappers like Compose record nothing themselves,
+// only the options they apply
+type optionKind string
+
+// codeKind returns kind of option func o from the constructor whose closure it is
+func codeKind(o any) optionKind {
+	f := runtime.FuncForPC(reflect.ValueOf(o).Pointer())
+	if f == nil {
+		return "unknown option"
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, ".func"); i > 0 {
+		name = name[:i] // closure returned by the constructor
+	}
+	name = name[strings.LastIndex(name, "/")+1:]
+	if _, fn, ok := strings.Cut(name, "."); ok {
+		name = fn // drop the package
+	}
+	return optionKind(name)
+}
+
+// kindsOf returns kinds o records when applied, found by applying it to throwaway params
+func kindsOf(o OptReqParamsOption) []optionKind {
+	probe := NewOptReqParams()
+	probe.appliedKinds = nil
+	applyOption(probe, o)
+	return probe.appliedKinds
+}
+
+// optionConflict is a rule declared by ConflictsWith, kinds of the option it was declared on and the
+// kinds it is incompatible with
+type optionConflict struct {
+	own, incompatible []optionKind
+}
+
+// applyOption applies o on s and records its kind so ConflictsWith can find it, unless o took care of that
+// itself, see withKind and transparent
+func applyOption(s *OptReqParams, o OptReqParamsOption) {
+	outer := s.kindRecorded
+	s.kindRecorded = false
+	o(s)
+	if !s.kindRecorded {
+		s.appliedKinds = append(s.appliedKinds, codeKind(o))
+	}
+	s.kindRecorded = outer
+}
+
+// withKind returns o identified as kind, for constructors which build their option with another constructor
+// or a helper shared by several of them. o itself is not recorded
+func withKind(kind optionKind, o OptReqParamsOption) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.kindRecorded = true
+		s.appliedKinds = append(s.appliedKinds, kind)
+		o(s)
+	}
+}
+
+// transparent marks option being applied as a wrapper with no kind of its own, options it applies with
+// applyOption are recorded as usual
+func transparent(s *OptReqParams) {
+	s.kindRecorded = true
+}
+
+// WithDependency returns option applying o and then dependent, for options which only make sense together,
+// like WithGzipBody().WithDependency(WithHeader("X-Body-Encoding", "gzip"))
+func (o OptReqParamsOption) WithDependency(dependent OptReqParamsOption) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		transparent(s)
+		applyOption(s, o)
+		applyOption(s, dependent)
+	}
+}
+
+// ConflictsWith returns option applying o which also declares it mutually exclusive with incompatible.
+// if an option of the same kind as incompatible is applied too, before or after, CustomHTTPRequest fails
+// with ErrConflictingOptions without making any call. kind is the constructor which made an option, so
+// WithBasicAuth(a, b).ConflictsWith(WithBearerToken("")) conflicts with any bearer token, or the name given
+// to NamedOption, which is the way to tell apart options of one constructor. Options need to be applied by
+// NewOptReqParams, With, Compose or WithConditional to be found
+func (o OptReqParamsOption) ConflictsWith(incompatible OptReqParamsOption) OptReqParamsOption {
+	kinds := kindsOf(incompatible)
+	return func(s *OptReqParams) {
+		transparent(s)
+		n := len(s.appliedKinds)
+		applyOption(s, o)
+		own := slices.Clone(s.appliedKinds[n:])
+		s.optionConflicts = append(s.optionConflicts, optionConflict{own: own, incompatible: kinds})
+	}
+}
+
+// declaredConflicts returns names of option kinds of ConflictsWith rules of p which were applied together
+func (p *OptReqParams) declaredConflicts() [][2]string {
+	count := make(map[optionKind]int, len(p.appliedKinds))
+	for _, k := range p.appliedKinds {
+		count[k]++
+	}
+	var found [][2]string
+	for _, c := range p.optionConflicts {
+		for _, k := range c.incompatible {
+			// option the rule was declared on counts only when it is applied again
+			if count[k] > countKind(c.own, k) {
+				found = append(found, [2]string{joinKinds(c.own), string(k)})
+				break
+			}
+		}
+	}
+	return found
+}
+
+// countKind returns how many times k is in kinds
+func countKind(kinds []optionKind, k optionKind) int {
+	n := 0
+	for _, kind := range kinds {
+		if kind == k {
+			n++
+		}
+	}
+	return n
+}
+
+// joinKinds returns kinds as one name for error messages
+func joinKinds(kinds []optionKind) string {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, "+")
+}
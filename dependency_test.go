@@ -0,0 +1,112 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithDependency(t *testing.T) {
+	srv := newRecordingServer(t)
+	_, _ = mustCall(t, srv.URL, WithMethod(http.MethodPost), WithRawBody([]byte("payload")),
+		WithGzipBody().WithDependency(WithHeader("X-Body-Encoding", "gzip")))
+	r, body := srv.last(t)
+	if r.Header.Get("X-Body-Encoding") != "gzip" || r.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("headers X-Body-Encoding %q, Content-Encoding %q, want both gzip",
+			r.Header.Get("X-Body-Encoding"), r.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "payload" {
+		t.Errorf("server got %q, want gzipped payload", b)
+	}
+
+	// dependent goes after, so it wins
+	p := NewOptReqParams(WithTimeout(time.Second).WithDependency(WithTimeout(2 * time.Second)))
+	if p.timeout != 2*time.Second {
+		t.Errorf("timeout %v, want dependent applied last", p.timeout)
+	}
+}
+
+func TestConflictsWith(t *testing.T) {
+	srv := newRecordingServer(t)
+	gzipOnly := WithGzipBody().ConflictsWith(WithBrotliBody())
+	for _, tc := range []struct {
+		name     string
+		opts     []OptReqParamsOption
+		conflict bool
+	}{
+		{"alone", []OptReqParamsOption{gzipOnly}, false},
+		{"other after", []OptReqParamsOption{gzipOnly, WithBrotliBody()}, true},
+		{"other before", []OptReqParamsOption{WithBrotliBody(), gzipOnly}, true},
+		{"other composed", []OptReqParamsOption{gzipOnly, Compose(WithTimeout(time.Second), WithBrotliBody())}, true},
+		{"unrelated", []OptReqParamsOption{gzipOnly, WithHeader("X-A", "1")}, false},
+		{"same kind once", []OptReqParamsOption{WithHeader("X-A", "1").ConflictsWith(WithHeader("", ""))}, false},
+		{"same kind twice", []OptReqParamsOption{WithHeader("X-A", "1").ConflictsWith(WithHeader("", "")),
+			WithHeader("X-B", "2")}, true},
+	} {
+		before := srv.count()
+		res, err := call(t, srv.URL, append([]OptReqParamsOption{WithMethod(http.MethodPost),
+			WithRawBody([]byte("x"))}, tc.opts...)...)
+		readBody(t, res)
+		if got := errors.Is(err, ErrConflictingOptions); got != tc.conflict {
+			t.Errorf("%s: error %v, want conflict %v", tc.name, err, tc.conflict)
+		}
+		if tc.conflict && srv.count() != before {
+			t.Errorf("%s: conflicting request was sent", tc.name)
+		}
+	}
+}
+
+func TestConflictsWithNamesOptions(t *testing.T) {
+	p := NewOptReqParams(WithGzipBody().ConflictsWith(WithBrotliBody()))
+	err := p.With(WithBrotliBody()).Validate()
+	if err == nil || !strings.Contains(err.Error(), "WithGzipBody") || !strings.Contains(err.Error(), "WithBrotliBody") {
+		t.Errorf("error %v, want it to name both options", err)
+	}
+}
+
+func TestConflictsWithSameFactory(t *testing.T) {
+	basic := NamedOption("basic", WithBasicAuth("user", "passwd"))
+	for _, tc := range []struct {
+		name     string
+		own      OptReqParamsOption
+		other    OptReqParamsOption
+		applied  OptReqParamsOption
+		conflict bool
+	}{
+		{"other named option", WithUserAgent("x"), basic, NamedOption("ua-tenant", WithHeader("X-Tenant", "a")), false},
+		{"same name", WithUserAgent("x"), basic, NamedOption("basic", WithBasicAuth("other", "passwd")), true},
+		{"named is not its inner option", WithUserAgent("x"), basic, WithBasicAuth("user", "passwd"), false},
+		{"api key in query", WithUserAgent("x"), WithAPIKeyInHeader("k", ""), WithAPIKeyInQuery("k", ""), false},
+		{"api key in header", WithUserAgent("x"), WithAPIKeyInHeader("k", ""), WithAPIKeyInHeader("other", "X-Key"), true},
+		{"redirects", WithUserAgent("x"), NoFollow(), MaxRedirects(3), false},
+		{"max age", WithUserAgent("x"), WithCacheControl("no-store"), WithMaxAge(60), false},
+		{"form field", WithUserAgent("x"), WithFormBody(nil), WithFormField("a", "1"), false},
+		{"composed", WithUserAgent("x"), Compose(WithTimeout(time.Second)), Compose(WithMaxRetries(1)), false},
+		{"conditional", WithUserAgent("x"), WithConditional(true, WithTimeout(time.Second)),
+			WithConditional(true, WithMaxRetries(1)), false},
+		{"dependency", WithUserAgent("x"), WithTimeout(time.Second).WithDependency(WithHeader("X-A", "1")),
+			WithMaxRetries(1).WithDependency(WithAcceptLanguage("en")), false},
+		{"option error", WithUserAgent("x"), WithAPIKeyInHeader("", ""), WithBaggageHeader("no value"), false},
+	} {
+		err := NewOptReqParams(tc.own.ConflictsWith(tc.other), tc.applied).Validate()
+		if got := errors.Is(err, ErrConflictingOptions); got != tc.conflict {
+			t.Errorf("%s: error %v, want conflict %v", tc.name, err, tc.conflict)
+		}
+	}
+}
+
+func TestConflictsWithNamesNamedOption(t *testing.T) {
+	basic := NamedOption("basic", WithBasicAuth("user", "passwd"))
+	err := NewOptReqParams(WithUserAgent("x").ConflictsWith(basic), basic).Validate()
+	if err == nil || !strings.Contains(err.Error(), `NamedOption("basic")`) || !strings.Contains(err.Error(), "WithUserAgent") {
+		t.Errorf("error %v, want it to name both options", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// same dial timeout http.DefaultTransport uses
+const defaultDialTimeout = 30 * time.Second
+
+// WithDisableKeepAlives closes every connection after its request, for long lived processes calling
+// many different hosts once. Requests go out with Connection: close
+func WithDisableKeepAlives() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.disableKeepAlives = true
+	}
+}
+
+// WithKeepAliveInterval sets TCP keep-alive probe interval of new connections
+func WithKeepAliveInterval(d time.Duration) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.keepAliveInterval = d
+	}
+}
+
+// WithCustomDialer makes the transport open connections with d, for things like binding to a local address
+// or own dial timeouts. d must not be modified once it was used
+func WithCustomDialer(d *net.Dialer) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.dialer, s.dialerKey = d, ""
+	}
+}
+
+// WithLocalAddr makes connections go out from local addr, an ip with optional port like 10.0.0.5 or
+// 10.0.0.5:0. It replaces dialer of WithCustomDialer. A bad addr is returned by CustomHTTPRequest without
+// making any call
+func WithLocalAddr(addr string) OptReqParamsOption {
+	local, err := parseLocalAddr(addr)
+	d := &net.Dialer{Timeout: defaultDialTimeout, LocalAddr: local}
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: local addr: %v", ErrInvalidOption, err))
+			return
+		}
+		s.dialer, s.dialerKey = d, "local "+addr
+	}
+}
+
+// parseLocalAddr accepts ip with or without port, no lookup is done
+func parseLocalAddr(addr string) (*net.TCPAddr, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return &net.TCPAddr{IP: ip}, nil
+	}
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	return net.TCPAddrFromAddrPort(ap), nil
+}
+
+// WithHostOverride connects to targetAddr whatever host the url has, while url scheme and Host header stay
+// the same, like curl --resolve. targetAddr is host:port or just host, then port of the url is kept.
+// TLS handshake still uses host of the url as server name
+func WithHostOverride(targetAddr string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.hostOverride = targetAddr
+	}
+}
+
+// WithPreferIPv6 connects to IPv6 addresses of a host first and falls back to IPv4 ones only when they fail.
+// The last one of WithPreferIPv6 and WithPreferIPv4 wins
+func WithPreferIPv6() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.preferIPFamily = "ip6"
+	}
+}
+
+// WithPreferIPv4 connects to IPv4 addresses of a host first and falls back to IPv6 ones only when they fail.
+// The last one of WithPreferIPv6 and WithPreferIPv4 wins
+func WithPreferIPv4() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.preferIPFamily = "ip4"
+	}
+}
+
+// systemLookup returns lookup done with r, nil r means net.DefaultResolver
+func systemLookup(r *net.Resolver) func(ctx context.Context, network, host string) ([]net.IP, error) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return func(ctx context.Context, network, host string) ([]net.IP, error) {
+		ipNetwork := "ip"
+		switch network {
+		case "tcp4":
+			ipNetwork = "ip4"
+		case "tcp6":
+			ipNetwork = "ip6"
+		}
+		return r.LookupIP(ctx, ipNetwork, host)
+	}
+}
+
+// preferFamily returns lookup which puts addresses of family, ip4 or ip6, before the others keeping their order
+func preferFamily(lookup func(ctx context.Context, network, host string) ([]net.IP, error),
+	family string) func(ctx context.Context, network, host string) ([]net.IP, error) {
+	return func(ctx context.Context, network, host string) ([]net.IP, error) {
+		ips, err := lookup(ctx, network, host)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortStableFunc(ips, func(a, b net.IP) int {
+			return cmp.Compare(familyRank(b, family), familyRank(a, family))
+		})
+		return ips, nil
+	}
+}
+
+// familyRank is 1 for ip of family and 0 otherwise
+func familyRank(ip net.IP, family string) int {
+	if (ip.To4() != nil) == (family == "ip4") {
+		return 1
+	}
+	return 0
+}
+
+// overrideHost returns dial which replaces host of every address with target
+func overrideHost(dial func(ctx context.Context, network, addr string) (net.Conn, error),
+	target string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if _, _, err := net.SplitHostPort(target); err == nil {
+			return dial(ctx, network, target)
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, net.JoinHostPort(target, port))
+	}
+}
+
+// dialContext returns dial func for the transport out of dialing options of p, nil means transport's own one
+func dialContext(p *OptReqParams) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if !p.needsOwnDialer() {
+		return nil
+	}
+	d := &net.Dialer{Timeout: defaultDialTimeout}
+	if p.dialer != nil {
+		c := *p.dialer // copy so caller's dialer is never modified
+		d = &c
+	}
+	if p.keepAliveInterval > 0 {
+		d.KeepAlive = p.keepAliveInterval
+	}
+
+	// resolve names ourselves only when system resolver or its address order is not good enough
+	var lookup func(ctx context.Context, network, host string) ([]net.IP, error)
+	if resolverURL := p.dohURL; resolverURL != "" {
+		lookup = func(ctx context.Context, network, host string) ([]net.IP, error) {
+			return lookupDoH(ctx, resolverURL, network, host)
+		}
+	}
+	if p.preferIPFamily != "" {
+		if lookup == nil {
+			lookup = systemLookup(d.Resolver)
+		}
+		lookup = preferFamily(lookup, p.preferIPFamily)
+	}
+
+	dial := d.DialContext
+	if lookup != nil {
+		dial = resolvingDial(dial, lookup)
+	}
+	if p.hostOverride != "" {
+		dial = overrideHost(dial, p.hostOverride)
+	}
+	return dial
+}
+
+// needsOwnDialer tells whether p has dialing options, otherwise transport keeps its own dial func
+func (p *OptReqParams) needsOwnDialer() bool {
+	return p.dialer != nil || p.keepAliveInterval > 0 || p.hostOverride != "" || p.dohURL != "" ||
+		p.preferIPFamily != ""
+}
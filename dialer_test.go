@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDisableKeepAlives(t *testing.T) {
+	var closes []bool
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		closes = append(closes, r.Close && r.Header.Get("Connection") == "close")
+	})
+	for range 3 {
+		mustCall(t, srv.URL, WithDisableKeepAlives())
+	}
+	for i, c := range closes {
+		if !c {
+			t.Errorf("request %d came without Connection: close", i)
+		}
+	}
+	if n := srv.conns.Load(); n != 3 {
+		t.Errorf("3 calls opened %d connections, want one each", n)
+	}
+}
+
+func TestKeepAliveInterval(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.Close {
+			t.Error("request asked to close connection")
+		}
+	})
+	for range 3 {
+		mustCall(t, srv.URL, WithKeepAliveInterval(5*time.Second))
+	}
+	if n := srv.conns.Load(); n != 1 {
+		t.Errorf("3 calls opened %d connections, want 1", n)
+	}
+}
+
+func TestCustomDialerUsed(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	var dials atomic.Int32
+	d := &net.Dialer{ControlContext: func(ctx context.Context, network, address string, c syscall.RawConn) error {
+		dials.Add(1)
+		return nil
+	}}
+	mustCall(t, srv.URL, WithCustomDialer(d))
+	if n := dials.Load(); n != 1 {
+		t.Errorf("custom dialer dialed %d times, want 1", n)
+	}
+
+	// *net.Dialer can't hand out a net.Pipe, failing its control hook shows the call depends on it instead
+	dialErr := errors.New("dial refused by test")
+	d = &net.Dialer{ControlContext: func(context.Context, string, string, syscall.RawConn) error { return dialErr }}
+	if _, err := call(t, srv.URL, WithCustomDialer(d)); !errors.Is(err, dialErr) {
+		t.Errorf("err = %v, want error of custom dialer", err)
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := ln.Addr().String() // free port to bind the client side to
+	ln.Close()
+
+	var remote string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) { remote = r.RemoteAddr })
+	mustCall(t, srv.URL, WithLocalAddr(local))
+	if remote != local {
+		t.Errorf("server saw client at %s, want %s", remote, local)
+	}
+	if _, err := call(t, srv.URL, WithLocalAddr("not an ip")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	var host string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) { host = r.Host })
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	for name, tt := range map[string]struct{ url, target string }{
+		"host only":     {"http://api.example.invalid:" + port + "/x", "127.0.0.1"},
+		"host and port": {"http://api.example.invalid:81/x", "127.0.0.1:" + port},
+	} {
+		host = ""
+		mustCall(t, tt.url, WithHostOverride(tt.target))
+		want := strings.TrimPrefix(strings.TrimSuffix(tt.url, "/x"), "http://")
+		if host != want {
+			t.Errorf("%s: server got Host %q, want %q", name, host, want)
+		}
+	}
+}
+
+// newDualStackServer serves on the same port of 127.0.0.1 and ::1, answering with the family that was reached
+func newDualStackServer(t *testing.T) (port string) {
+	t.Helper()
+	for range 10 {
+		ln4, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, port, _ = net.SplitHostPort(ln4.Addr().String())
+		ln6, err := net.Listen("tcp6", "[::1]:"+port)
+		if err != nil {
+			ln4.Close()
+			continue // port taken on ::1 or no IPv6 at all, try another one
+		}
+		for family, ln := range map[string]net.Listener{"ip4": ln4, "ip6": ln6} {
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, family)
+			})}
+			go srv.Serve(ln)
+			t.Cleanup(func() { srv.Close() })
+		}
+		return port
+	}
+	t.Skip("no dual-stack loopback")
+	return ""
+}
+
+func TestPreferIPFamily(t *testing.T) {
+	doh := newDoHServer(t) // resolves api.test.invalid to 127.0.0.1 and ::1 in this order
+	url := "http://api.test.invalid:" + newDualStackServer(t)
+	tests := []struct {
+		name string
+		opts []OptReqParamsOption
+		want string
+	}{
+		{"resolver order", nil, "ip4"},
+		{"prefer ipv6", []OptReqParamsOption{WithPreferIPv6()}, "ip6"},
+		{"prefer ipv4", []OptReqParamsOption{WithPreferIPv6(), WithPreferIPv4()}, "ip4"},
+		{"last wins", []OptReqParamsOption{WithPreferIPv4(), WithPreferIPv6()}, "ip6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, body := mustCall(t, url, append([]OptReqParamsOption{WithDNSOverHTTPS(doh.URL)}, tt.opts...)...)
+			if body != tt.want {
+				t.Errorf("connected over %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreferFamilyOrder(t *testing.T) {
+	lookup := func(context.Context, string, string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1"), net.ParseIP("10.0.0.2"), net.ParseIP("fd00::2")}, nil
+	}
+	ips, err := preferFamily(lookup, "ip6")(context.Background(), "tcp", "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ip := range ips {
+		got = append(got, ip.String())
+	}
+	if want := []string{"fd00::1", "fd00::2", "10.0.0.1", "10.0.0.2"}; !slices.Equal(got, want) {
+		t.Errorf("order %q, want %q", got, want)
+	}
+}
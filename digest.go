@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithDigestAuth uses HTTP Digest authentication instead of bearer token from MyLoginAPI. Request is first sent
+// without credentials and when server answers 401 with a Digest challenge, it is sent again with computed
+// Authorization header. Both requests happen inside the transport, caller only sees the final response.
+// Server asking for qop=auth-int gets body hashed into the response, so body is read twice
+func WithDigestAuth(username, password string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.useDigestAuth = true
+		s.digestUser = username
+		s.digestPasswd = password
+	}
+}
+
+// digestTransport answers Digest challenges returned by next
+type digestTransport struct {
+	next     http.RoundTripper
+	username string
+	password string
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	challenge, ok := parseDigestChallenge(res.Header.Get("WWW-Authenticate"))
+	if !ok {
+		return res, nil // not a digest challenge, give 401 to caller as it is
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return res, nil // body was consumed and can't be sent again
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	qop, err := challenge.qop()
+	if err != nil {
+		return nil, err
+	}
+
+	second := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if second.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	var body []byte
+	if qop == "auth-int" && second.Body != nil {
+		body, err = io.ReadAll(second.Body)
+		_ = second.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		second.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	auth, err := challenge.authorize(second.Method, second.URL.RequestURI(), t.username, t.password, qop, body)
+	if err != nil {
+		return nil, err
+	}
+	second.Header.Set("Authorization", auth)
+	return t.next.RoundTrip(second)
+}
+
+// digestChallenge holds params of a WWW-Authenticate: Digest header
+type digestChallenge map[string]string
+
+// parseDigestChallenge parses header like: Digest realm="r", nonce="n", qop="auth,auth-int"
+func parseDigestChallenge(header string) (digestChallenge, bool) {
+	scheme, rest, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return nil, false
+	}
+
+	c := make(digestChallenge)
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			// quoted value may contain commas
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				return nil, false
+			}
+			c[key] = value[1 : end+1]
+			rest = strings.TrimPrefix(strings.TrimSpace(value[end+2:]), ",")
+			continue
+		}
+		v, next, _ := strings.Cut(value, ",")
+		c[key] = strings.TrimSpace(v)
+		rest = next
+	}
+	_, ok := c["nonce"]
+	return c, ok
+}
+
+// qop picks auth over auth-int, empty qop means server wants RFC 2069 digest without it
+func (c digestChallenge) qop() (string, error) {
+	offered, ok := c["qop"]
+	if !ok {
+		return "", nil
+	}
+	qop := ""
+	for q := range strings.SplitSeq(offered, ",") {
+		switch strings.TrimSpace(q) {
+		case "auth":
+			return "auth", nil
+		case "auth-int":
+			qop = "auth-int"
+		}
+	}
+	if qop == "" {
+		return "", fmt.Errorf("unsupported digest qop %q", offered)
+	}
+	return qop, nil
+}
+
+// authorize computes Authorization header answering the challenge with given qop, see RFC 7616.
+// Body is only used by auth-int
+func (c digestChallenge) authorize(method, uri, username, password, qop string, body []byte) (string, error) {
+	algorithm := c["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	h := func(s string) string {
+		d := newHash()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	cnonce := hex.EncodeToString(b[:])
+	const nc = "00000001" // every challenge is answered once
+
+	ha1 := h(username + ":" + c["realm"] + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c["nonce"] + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	if qop == "auth-int" {
+		ha2 = h(method + ":" + uri + ":" + h(string(body)))
+	}
+
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, c["nonce"], nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + c["nonce"] + ":" + ha2)
+	}
+
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, username),
+		fmt.Sprintf(`realm="%s"`, c["realm"]),
+		fmt.Sprintf(`nonce="%s"`, c["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`algorithm=%s`, algorithm),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if qop != "" {
+		parts = append(parts, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, ok := c["opaque"]; ok {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	return "Digest " + strings.Join(parts, ", "), nil
+}
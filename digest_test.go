@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	testDigestRealm = "testrealm@host.com"
+	testDigestNonce = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+)
+
+func md5Hex(s string) string {
+	d := md5.Sum([]byte(s))
+	return hex.EncodeToString(d[:])
+}
+
+// newDigestServer answers with a challenge offering qop, or RFC 2069 digest when qop is empty,
+// and checks response of the second request like a server would
+func newDigestServer(t *testing.T, qop string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		auth, ok := parseDigestChallenge(r.Header.Get("Authorization"))
+		if !ok {
+			challenge := `Digest realm="` + testDigestRealm + `", nonce="` + testDigestNonce + `", opaque="o"`
+			if qop != "" {
+				challenge += `, qop="` + qop + `"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ha1 := md5Hex("Mufasa:" + testDigestRealm + ":Circle Of Life")
+		ha2 := md5Hex(r.Method + ":" + auth["uri"])
+		if auth["qop"] == "auth-int" {
+			ha2 = md5Hex(r.Method + ":" + auth["uri"] + ":" + md5Hex(string(body)))
+		}
+		want := md5Hex(ha1 + ":" + testDigestNonce + ":" + ha2)
+		if auth["qop"] != "" {
+			want = md5Hex(strings.Join([]string{ha1, testDigestNonce, auth["nc"], auth["cnonce"], auth["qop"], ha2}, ":"))
+		}
+		if auth["response"] != want || auth["opaque"] != "o" || auth["uri"] != r.URL.RequestURI() {
+			t.Errorf("bad digest %q, want response %s", r.Header.Get("Authorization"), want)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = io.WriteString(w, auth["qop"]+" "+string(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func digestCall(t *testing.T, url string, opts ...OptReqParamsOption) (*http.Response, error) {
+	t.Helper()
+	p := NewOptReqParams(append([]OptReqParamsOption{WithDigestAuth("Mufasa", "Circle Of Life")}, opts...)...)
+	return CustomHTTPRequest(t.Context(), url, "user@example.com", "passwd", p)
+}
+
+func TestDigestAuth(t *testing.T) {
+	tests := []struct {
+		name, qop, method, want string
+	}{
+		{"qop auth", "auth", http.MethodGet, "auth "},
+		{"auth preferred over auth-int", "auth-int,auth", http.MethodPost, "auth payload"},
+		{"qop auth-int", "auth-int", http.MethodPost, "auth-int payload"},
+		{"auth-int without body", "auth-int", http.MethodGet, "auth-int "},
+		{"rfc 2069", "", http.MethodGet, " "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := newDigestServer(t, tt.qop)
+			opts := []OptReqParamsOption{WithMethod(tt.method)}
+			if tt.method == http.MethodPost {
+				opts = append(opts, WithRawBody([]byte("payload")))
+			}
+			res, err := digestCall(t, srv.URL+"/dir/index.html?x=1", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status %d", res.StatusCode)
+			}
+			if got := readBody(t, res); got != tt.want {
+				t.Errorf("body %q, want %q", got, tt.want)
+			}
+			if n := calls.Load(); n != 2 {
+				t.Errorf("server got %d requests, want 2", n)
+			}
+		})
+	}
+}
+
+func TestDigestAuthUnsupportedQop(t *testing.T) {
+	srv, _ := newDigestServer(t, "auth-conf")
+	res, err := digestCall(t, srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "unsupported digest qop") {
+		t.Errorf("err = %v, want unsupported qop error", err)
+	}
+	if res != nil {
+		res.Body.Close()
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrDryRun is matched by the error CustomHTTPRequest returns with WithDryRun, use errors.As with *DryRunError
+// to get the request which would have been sent
+var ErrDryRun = errors.New("dry run, request not sent")
+
+// DryRunError carries the fully built request of a dry run
+type DryRunError struct {
+	Request *http.Request
+}
+
+func (e *DryRunError) Error() string {
+	return ErrDryRun.Error()
+}
+
+// Is makes errors.Is(err, ErrDryRun) work
+func (e *DryRunError) Is(target error) bool {
+	return target == ErrDryRun
+}
+
+// WithDryRun makes CustomHTTPRequest build the request, run headers, auth, middleware and signing on it, and
+// return it in a *DryRunError instead of sending it. Nothing touches the network, so login api and oauth2
+// token endpoint are not called and the request goes without Authorization in those modes.
+// A file body given with WithBodyFromFile is already closed in the returned request
+func WithDryRun() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.dryRun = true
+	}
+}
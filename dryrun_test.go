@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run sent %s %s", r.Method, r.URL)
+	})
+	_, err := callWithAuth(t, srv.URL+"/users/{id}", WithDryRun(), WithMethod(http.MethodPut),
+		WithPathParams(map[string]string{"id": "7"}), WithQueryParamSingle("v", "2"), WithBearerToken("tok"),
+		WithJSONBody(map[string]int{"n": 1}), WithHealthCheck("/health", 0))
+	if !errors.Is(err, ErrDryRun) {
+		t.Fatalf("err = %v, want ErrDryRun", err)
+	}
+	var dry *DryRunError
+	if !errors.As(err, &dry) {
+		t.Fatalf("err %T is not a *DryRunError", err)
+	}
+	req := dry.Request
+	if req.Method != http.MethodPut || req.URL.String() != srv.URL+"/users/7?v=2" {
+		t.Errorf("request %s %s", req.Method, req.URL)
+	}
+	if req.Header.Get("Authorization") != "Bearer tok" || req.Header.Get("Content-Type") != ContentTypeJSON {
+		t.Errorf("headers %v", req.Header)
+	}
+	if b, _ := io.ReadAll(req.Body); string(b) != `{"n":1}` {
+		t.Errorf("body %q", b)
+	}
+	if n := srv.conns.Load(); n != 0 {
+		t.Errorf("dry run opened %d connections", n)
+	}
+}
+
+func TestDryRunStillValidates(t *testing.T) {
+	_, err := call(t, "http://example.invalid/{id}", WithDryRun(), WithPathParams(map[string]string{"other": "x"}))
+	if !errors.Is(err, ErrMissingPathParam) {
+		t.Errorf("err = %v, want ErrMissingPathParam", err)
+	}
+	if _, err := call(t, "http://example.invalid", WithDryRun(), WithBearerToken("tok")); !errors.Is(err, ErrConflictingOptions) {
+		t.Errorf("err = %v, want ErrConflictingOptions", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// WithIfNoneMatch sends If-None-Match so server can answer 304 Not Modified, which is returned as normal response
+func WithIfNoneMatch(etag string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.ifNoneMatch = etag
+	}
+}
+
+// WithETag sends If-Match, for optimistic concurrency on PUT or PATCH
+func WithETag(etag string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.ifMatch = etag
+	}
+}
+
+// ETagStore keeps last seen ETag per url for WithETagCache
+type ETagStore interface {
+	Get(url string) (etag string, ok bool)
+	Set(url, etag string)
+}
+
+// WithETagCache sends ETag cached in store for request url as If-None-Match, unless WithIfNoneMatch was given,
+// and caches ETag of every 200 response. NewMemoryETagStore gives a ready to use store
+func WithETagCache(store ETagStore) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.etagStore = store
+	}
+}
+
+// MemoryETagStore is an ETagStore backed by sync.Map, safe for concurrent use
+type MemoryETagStore struct {
+	m sync.Map
+}
+
+// NewMemoryETagStore returns an empty in memory ETagStore
+func NewMemoryETagStore() *MemoryETagStore {
+	return &MemoryETagStore{}
+}
+
+// Get returns etag cached for url
+func (s *MemoryETagStore) Get(url string) (string, bool) {
+	v, ok := s.m.Load(url)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+// Set caches etag for url
+func (s *MemoryETagStore) Set(url, etag string) {
+	s.m.Store(url, etag)
+}
+
+// applyConditionalHeaders sets If-None-Match and If-Match headers on req
+func applyConditionalHeaders(req *http.Request, p *OptReqParams) {
+	if p.ifMatch != "" {
+		req.Header.Set("If-Match", p.ifMatch)
+	}
+	switch {
+	case p.ifNoneMatch != "":
+		req.Header.Set("If-None-Match", p.ifNoneMatch)
+	case p.etagStore != nil:
+		if etag, ok := p.etagStore.Get(req.URL.String()); ok {
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+}
+
+// updateETagCache caches ETag of a 200 response if WithETagCache was given. ETag is cached for the url
+// which was requested, not the one redirected to, since that is what the next request looks up
+func updateETagCache(res *http.Response, p *OptReqParams) {
+	if p.etagStore == nil || res.StatusCode != http.StatusOK || res.Request == nil {
+		return
+	}
+	if etag := res.Header.Get("ETag"); etag != "" {
+		p.etagStore.Set(requestedURL(res), etag)
+	}
+}
+
+// requestedURL walks redirects of res back to the first request
+func requestedURL(res *http.Response) string {
+	req := res.Request
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req.URL.String()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestETagCacheSendsCachedETag(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("data"))
+	})
+	store := NewMemoryETagStore()
+	if res, _ := mustCall(t, srv.URL+"/doc", WithETagCache(store)); res.StatusCode != http.StatusOK {
+		t.Fatalf("first call status %d, want 200", res.StatusCode)
+	}
+	if res, _ := mustCall(t, srv.URL+"/doc", WithETagCache(store)); res.StatusCode != http.StatusNotModified {
+		t.Errorf("second call status %d, want 304 for cached etag", res.StatusCode)
+	}
+}
+
+func TestETagCacheKeyedOnRequestedURL(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/old":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte("data"))
+		}
+	})
+	store := NewMemoryETagStore()
+	mustCall(t, srv.URL+"/old", WithETagCache(store))
+	if etag, ok := store.Get(srv.URL + "/old"); !ok || etag != `"v1"` {
+		t.Errorf("etag for requested url %q, %v, want \"v1\"", etag, ok)
+	}
+	if _, ok := store.Get(srv.URL + "/new"); ok {
+		t.Error("etag cached for the url redirected to")
+	}
+	if res, _ := mustCall(t, srv.URL+"/old", WithETagCache(store)); res.StatusCode != http.StatusNotModified {
+		t.Errorf("second call status %d, want 304 for cached etag", res.StatusCode)
+	}
+}
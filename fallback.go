@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// WithFallbackURL adds a fallback for when the primary url fails, see WithFallbackURLs
+func WithFallbackURL(u string) OptReqParamsOption {
+	return withKind("WithFallbackURL", WithFallbackURLs(u))
+}
+
+// WithFallbackURLs adds fallbacks tried in order when the request fails with a network error or a 5xx
+// status, after retries on the previous url were used up. Fallbacks are origins like https://backup.example.com,
+// the request goes to the same path and query on them. A bad url is returned by CustomHTTPRequest without
+// making any call. Multiple calls add more fallbacks
+func WithFallbackURLs(urls ...string) OptReqParamsOption {
+	parsed := make([]*url.URL, 0, len(urls))
+	var errs []error
+	for _, u := range urls {
+		p, err := url.Parse(u)
+		if err == nil && (p.Scheme == "" || p.Host == "") {
+			err = errors.New("scheme and host are required")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: fallback url %q: %v", ErrInvalidOption, u, err))
+			continue
+		}
+		parsed = append(parsed, p)
+	}
+	return func(s *OptReqParams) {
+		s.optErrs = append(s.optErrs, errs...)
+		s.fallbackURLs = append(s.fallbackURLs, parsed...)
+	}
+}
+
+// doWithFallback does the request on primary url and then on fallbacks of p until one doesn't fail.
+// When the last fallback fails with an error too, the error joins what happened on every url, in the
+// order they were tried, so its last line tells which url was tried last
+func doWithFallback(ctx context.Context, rawURL, email, passwd string, p *OptReqParams) (*http.Response, error) {
+	if len(p.fallbackURLs) == 0 {
+		return doRequest(ctx, rawURL, email, passwd, p)
+	}
+
+	// body reader can be read only once but may have to be sent to every url
+	if p.body != nil && p.bodyBytes == nil {
+		b, err := io.ReadAll(p.body)
+		if err != nil {
+			return nil, err
+		}
+		p = p.Clone()
+		p.body, p.bodyBytes = nil, b
+	}
+
+	res, err := doRequest(ctx, rawURL, email, passwd, p)
+	failures := []error{fallbackFailure("url "+rawURL, res, err)}
+	for _, fb := range p.fallbackURLs {
+		if ctx.Err() != nil || !shouldFallback(res, err) {
+			return res, err
+		}
+		if res != nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+
+		attempt := p.Clone()
+		attempt.origin = fb
+		res, err = doRequest(ctx, rawURL, email, passwd, attempt)
+		failures = append(failures, fallbackFailure("fallback url "+fb.String(), res, err))
+	}
+	if err != nil {
+		err = errors.Join(failures...)
+	}
+	return res, err
+}
+
+// fallbackFailure describes outcome of request on the url named by what, when it failed
+func fallbackFailure(what string, res *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	if res != nil {
+		return fmt.Errorf("%s: status %d", what, res.StatusCode)
+	}
+	return nil
+}
+
+// shouldFallback tells whether outcome of a request is a failure which another url may not have.
+// call being invalid, dry run or a status validator failing on a non 5xx status are not
+func shouldFallback(res *http.Response, err error) bool {
+	if err == nil {
+		return res != nil && res.StatusCode >= http.StatusInternalServerError
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= http.StatusInternalServerError
+	}
+	return !errors.Is(err, ErrInvalidOption) && !errors.Is(err, ErrConflictingOptions) &&
+		!errors.Is(err, ErrMissingPathParam) && !errors.Is(err, ErrDryRun)
+}
+
+// withOrigin replaces scheme and host of rawURL with the ones of origin, nil origin leaves it as it is
+func withOrigin(rawURL string, origin *url.URL) (string, error) {
+	if origin == nil {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme, u.Host = origin.Scheme, origin.Host
+	return u.String(), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fallbackServers starts a server per status, each recording its name, path and body in order of calls
+func fallbackServers(t *testing.T, statuses ...int) ([]*countingServer, func() []string) {
+	var mu sync.Mutex
+	var calls []string
+	var srvs []*countingServer
+	for i, status := range statuses {
+		name := string(rune('a' + i))
+		srvs = append(srvs, newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			calls = append(calls, name+" "+r.URL.RequestURI()+" "+string(b))
+			mu.Unlock()
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(name))
+		}))
+	}
+	return srvs, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func TestFallbackURLsTriedInOrder(t *testing.T) {
+	srvs, calls := fallbackServers(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK, http.StatusOK)
+	res, body := mustCall(t, srvs[0].URL+"/items?id=1", WithMethod(http.MethodPost), WithBody(strings.NewReader("x")),
+		WithFallbackURL(srvs[1].URL), WithFallbackURLs(srvs[2].URL, srvs[3].URL))
+	if res.StatusCode != http.StatusOK || body != "c" {
+		t.Errorf("got %d %q, want 200 from the second fallback", res.StatusCode, body)
+	}
+	want := []string{"a /items?id=1 x", "b /items?id=1 x", "c /items?id=1 x"}
+	if got := calls(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("calls %q, want %q, stopping at the first success", got, want)
+	}
+}
+
+func TestFallbackNotUsedForClientError(t *testing.T) {
+	srvs, calls := fallbackServers(t, http.StatusNotFound, http.StatusOK)
+	res, _ := mustCall(t, srvs[0].URL, WithFallbackURL(srvs[1].URL))
+	if res.StatusCode != http.StatusNotFound || len(calls()) != 1 {
+		t.Errorf("got %d after %q, want 404 without fallback", res.StatusCode, calls())
+	}
+}
+
+func TestFallbackAllFail(t *testing.T) {
+	srvs, calls := fallbackServers(t, http.StatusInternalServerError, http.StatusBadGateway)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	_, err := call(t, srvs[0].URL+"/x", WithFallbackURLs(srvs[1].URL, closed.URL))
+	if err == nil {
+		t.Fatal("want error when every url fails")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	want := []string{
+		"url " + srvs[0].URL + "/x: status 500",
+		"fallback url " + srvs[1].URL + ": status 502",
+		"fallback url " + closed.URL + ": ",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("error %q, want %d lines", err, len(want))
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Errorf("error line %d %q, want prefix %q", i, lines[i], want[i])
+		}
+	}
+	if len(calls()) != 2 {
+		t.Errorf("calls %q, want both servers tried", calls())
+	}
+
+	// status errors of the urls are still found in it
+	_, err = call(t, srvs[0].URL, WithStatusValidator(func(code int) error {
+		if code >= 500 {
+			return &StatusError{StatusCode: code}
+		}
+		return nil
+	}), WithFallbackURL(srvs[1].URL))
+	var se *StatusError
+	if !errors.As(err, &se) || !strings.Contains(err.Error(), "fallback url "+srvs[1].URL) {
+		t.Errorf("error %v, want StatusError naming the fallback", err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+// FlagClient tells whether a feature flag is on, implemented by adapters of feature flag systems
+type FlagClient interface {
+	IsEnabled(flagName string) bool
+}
+
+// StaticFlagClient is a FlagClient with fixed flags, like StaticFlagClient{"new-api": true}. unknown flags are off
+type StaticFlagClient map[string]bool
+
+// IsEnabled returns value of flagName, false if it is not in c
+func (c StaticFlagClient) IsEnabled(flagName string) bool {
+	return c[flagName]
+}
+
+// WithFeatureFlag applies opt only when flagName is enabled in client. flag is checked when the option is
+// applied, that is when the params are constructed, so a flag flipped later affects only params made after it
+func WithFeatureFlag(client FlagClient, flagName string, opt OptReqParamsOption) OptReqParamsOption {
+	return WithConditionalFunc(func() bool { return client.IsEnabled(flagName) }, opt)
+}
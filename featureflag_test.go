@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// toggleFlag is a FlagClient whose only flag can be flipped while params are being made
+type toggleFlag struct{ on atomic.Bool }
+
+func (f *toggleFlag) IsEnabled(string) bool { return f.on.Load() }
+
+func TestFeatureFlag(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags StaticFlagClient
+		want  time.Duration
+	}{
+		{"on", StaticFlagClient{"short-timeout": true}, time.Second},
+		{"off", StaticFlagClient{"short-timeout": false}, 5 * time.Second},
+		{"unknown", StaticFlagClient{}, 5 * time.Second},
+	} {
+		p := NewOptReqParams(WithTimeout(5*time.Second),
+			WithFeatureFlag(tc.flags, "short-timeout", WithTimeout(time.Second)))
+		if p.timeout != tc.want {
+			t.Errorf("%s: timeout %v, want %v", tc.name, p.timeout, tc.want)
+		}
+	}
+}
+
+func TestFeatureFlagCheckedOnConstruction(t *testing.T) {
+	flag := &toggleFlag{}
+	opt := WithFeatureFlag(flag, "new-header", WithHeader("X-New", "1"))
+
+	off := NewOptReqParams(opt)
+	flag.on.Store(true)
+	on := NewOptReqParams(opt)
+	flag.on.Store(false)
+
+	if off.headers.Get("X-New") != "" {
+		t.Error("params made with flag off got the header")
+	}
+	if on.headers.Get("X-New") != "1" {
+		t.Error("params made with flag on didn't get the header, or lost it when flag was turned off")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// graphqlRequest is the standard GraphQL over HTTP request body
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+}
+
+// WithGraphQLBody sends query with its variables and operationName as GraphQL request, that is a JSON body
+// POSTed with ContentTypeJSON. variables and operationName can be left empty. An empty query or variables
+// which can't be marshaled are returned by CustomHTTPRequest, use WithGraphQLBodyE to get them at
+// construction time instead
+func WithGraphQLBody(query string, variables map[string]any, operationName string) OptReqParamsOption {
+	b, err := graphqlBody(query, variables, operationName)
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, err)
+			return
+		}
+		if err := setEncodedBody(s, "graphql", b, ContentTypeJSON); err != nil {
+			s.optErrs = append(s.optErrs, err)
+			return
+		}
+		s.httpMethod = http.MethodPost
+	}
+}
+
+// WithGraphQLQuery is WithGraphQLBody for a query without variables
+func WithGraphQLQuery(query string) OptReqParamsOption {
+	return withKind("WithGraphQLQuery", WithGraphQLBody(query, nil, ""))
+}
+
+// graphqlBody marshals GraphQL request body
+func graphqlBody(query string, variables map[string]any, operationName string) ([]byte, error) {
+	if query == "" {
+		return nil, fmt.Errorf("%w: empty graphql query", ErrInvalidOption)
+	}
+	b, err := json.Marshal(graphqlRequest{Query: query, Variables: variables, OperationName: operationName})
+	if err != nil {
+		return nil, fmt.Errorf("%w: graphql variables: %v", ErrInvalidOption, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGraphQLBody(t *testing.T) {
+	srv := newRecordingServer(t)
+	const query = `query User($id: ID!) { user(id: $id) { name } }`
+	mustCall(t, srv.URL, WithGraphQLBody(query, map[string]any{"id": "42"}, "User"))
+
+	req, body := srv.last(t)
+	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != ContentTypeJSON {
+		t.Errorf("%s with Content-Type %q", req.Method, req.Header.Get("Content-Type"))
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("body %q: %v", body, err)
+	}
+	want := map[string]any{"query": query, "variables": map[string]any{"id": "42"}, "operationName": "User"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("body %v, want %v", got, want)
+	}
+}
+
+func TestGraphQLQueryOmitsEmptyFields(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithGraphQLQuery("{ me { id } }"))
+	if _, body := srv.last(t); body != `{"query":"{ me { id } }"}` {
+		t.Errorf("body %s", body)
+	}
+}
+
+func TestGraphQLBodyErrors(t *testing.T) {
+	for name, opt := range map[string]OptReqParamsOption{
+		"empty query":     WithGraphQLQuery(""),
+		"bad variables":   WithGraphQLBody("{ a }", map[string]any{"ch": make(chan int)}, ""),
+		"mixed with json": Compose(WithJSONBody(1), WithGraphQLQuery("{ a }")),
+	} {
+		if _, err := call(t, "http://example.invalid", opt); err == nil {
+			t.Errorf("%s: no error", name)
+		} else if !errors.Is(err, ErrInvalidOption) && !errors.Is(err, ErrConflictingOptions) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// commonly used values for WithContentType
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeForm        = "application/x-www-form-urlencoded"
+	ContentTypeOctetStream = "application/octet-stream"
+	ContentTypeXML         = "application/xml"
+	ContentTypeProtobuf    = "application/x-protobuf"
+)
+
+// WithHeader adds a single request header, calling it again with same key adds one more value
+// instead of replacing the previous one
+func WithHeader(key, value string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		if s.headers == nil {
+			s.headers = make(http.Header)
+		}
+		s.headers.Add(key, value)
+	}
+}
+
+// WithHeaders adds a batch of request headers, same as calling WithHeader for each of them
+func WithHeaders(headers map[string]string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		for k, v := range headers {
+			WithHeader(k, v)(s)
+		}
+	}
+}
+
+// WithContentType sets Content-Type header of the request, default is ContentTypeJSON
+func WithContentType(ct string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.contentType = ct
+	}
+}
+
+// WithUserAgent sets User-Agent header, empty (default) keeps Go http client's own user agent
+func WithUserAgent(ua string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.userAgent = ua
+	}
+}
+
+// WithAcceptLanguage sets Accept-Language header, like "en-US,en;q=0.9"
+func WithAcceptLanguage(lang string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.acceptLanguage = lang
+	}
+}
+
+// WithPreferredLanguages builds Accept-Language from langs in order of preference, first one gets q=1.0
+// and every next one 0.1 less, never going below 0.1. ("en-US", "en", "fr") gives "en-US;q=1.0,en;q=0.9,fr;q=0.8"
+func WithPreferredLanguages(langs ...string) OptReqParamsOption {
+	parts := make([]string, 0, len(langs))
+	for i, l := range langs {
+		q := max(10-i, 1) // in tenths to avoid float rounding
+		parts = append(parts, fmt.Sprintf("%s;q=%d.%d", l, q/10, q%10))
+	}
+	return withKind("WithPreferredLanguages", WithAcceptLanguage(strings.Join(parts, ",")))
+}
+
+// WithCacheControl sets Cache-Control header, applying it again replaces the directive
+func WithCacheControl(directive string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.cacheControl = directive
+	}
+}
+
+// WithNoCache asks every cache on the way to be bypassed, with Pragma for old HTTP/1.0 caches
+func WithNoCache() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.cacheControl = "no-cache, no-store"
+		s.pragma = "no-cache"
+	}
+}
+
+// WithMaxAge sets Cache-Control: max-age=seconds
+func WithMaxAge(seconds int) OptReqParamsOption {
+	return withKind("WithMaxAge", WithCacheControl(fmt.Sprintf("max-age=%d", seconds)))
+}
+
+// applyHeaders sets default headers and then the ones given with WithHeader.
+// A custom header replaces a default one with same name, authentication is applied later and
+// so it can't be overwritten from here
+func applyHeaders(req *http.Request, p *OptReqParams) {
+	req.Header.Add("Accept", p.acceptHeader)
+	req.Header.Add("Content-Type", p.contentType)
+
+	for k, values := range p.headers {
+		req.Header.Del(k)
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	if p.rangeHeader != "" {
+		req.Header.Set("Range", p.rangeHeader)
+	}
+	if p.cacheControl != "" {
+		req.Header.Set("Cache-Control", p.cacheControl)
+	}
+	if p.pragma != "" {
+		req.Header.Set("Pragma", p.pragma)
+	}
+	if p.acceptLanguage != "" {
+		req.Header.Set("Accept-Language", p.acceptLanguage)
+	}
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestHeadersAccumulate(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL,
+		WithHeader("X-Multi", "a"),
+		WithHeader("X-Multi", "b"),
+		WithHeaders(map[string]string{"X-One": "1", "X-Two": "2"}),
+		WithHeader("X-One", "3"),
+	)
+	req, _ := srv.last(t)
+	for name, want := range map[string][]string{
+		"X-Multi": {"a", "b"},
+		"X-One":   {"1", "3"},
+		"X-Two":   {"2"},
+	} {
+		if got := req.Header.Values(name); !slices.Equal(got, want) {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestHeaderDoesNotOverrideAuth(t *testing.T) {
+	srv := newRecordingServer(t)
+	p := NewOptReqParams(WithBearerToken("real"), WithHeader("Authorization", "Bearer fake"))
+	res, err := CustomHTTPRequest(context.Background(), srv.URL, "user@example.com", "passwd", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	req, _ := srv.last(t)
+	if got := req.Header.Values("Authorization"); !slices.Equal(got, []string{"Bearer real"}) {
+		t.Errorf("Authorization = %q, want only the one of WithBearerToken", got)
+	}
+}
+
+func TestUserAgentSentOnce(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithHeader("User-Agent", "from-header"), WithUserAgent("my-client/1.0"))
+	req, _ := srv.last(t)
+	if got := req.Header.Values("User-Agent"); !slices.Equal(got, []string{"my-client/1.0"}) {
+		t.Errorf("User-Agent = %q, want it exactly once", got)
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  OptReqParamsOption
+		want string
+	}{
+		{"raw", WithAcceptLanguage("en-US,en;q=0.9"), "en-US,en;q=0.9"},
+		{"preferred", WithPreferredLanguages("en-US", "en", "fr"), "en-US;q=1.0,en;q=0.9,fr;q=0.8"},
+		{"floor at 0.1", WithPreferredLanguages("a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"),
+			"a;q=1.0,b;q=0.9,c;q=0.8,d;q=0.7,e;q=0.6,f;q=0.5,g;q=0.4,h;q=0.3,i;q=0.2,j;q=0.1,k;q=0.1"},
+	}
+	srv := newRecordingServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustCall(t, srv.URL, tt.opt)
+			req, _ := srv.last(t)
+			if got := req.Header.Values("Accept-Language"); !slices.Equal(got, []string{tt.want}) {
+				t.Errorf("Accept-Language = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheControlOnce(t *testing.T) {
+	tests := []struct {
+		name                 string
+		opts                 []OptReqParamsOption
+		cacheControl, pragma string
+	}{
+		{"applied twice", []OptReqParamsOption{WithCacheControl("no-transform"), WithCacheControl("no-transform")},
+			"no-transform", ""},
+		{"last wins", []OptReqParamsOption{WithCacheControl("no-transform"), WithMaxAge(60)}, "max-age=60", ""},
+		{"no cache twice", []OptReqParamsOption{WithNoCache(), WithNoCache()}, "no-cache, no-store", "no-cache"},
+		{"over custom header", []OptReqParamsOption{WithHeader("Cache-Control", "x"), WithNoCache()},
+			"no-cache, no-store", "no-cache"},
+	}
+	srv := newRecordingServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustCall(t, srv.URL, tt.opts...)
+			req, _ := srv.last(t)
+			if got := req.Header.Values("Cache-Control"); !slices.Equal(got, []string{tt.cacheControl}) {
+				t.Errorf("Cache-Control = %q, want %q once", got, tt.cacheControl)
+			}
+			wantPragma := []string{tt.pragma}
+			if tt.pragma == "" {
+				wantPragma = nil
+			}
+			if got := req.Header.Values("Pragma"); !slices.Equal(got, wantPragma) {
+				t.Errorf("Pragma = %q, want %q", got, wantPragma)
+			}
+		})
+	}
+}
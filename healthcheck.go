@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrEndpointUnhealthy is returned by CustomHTTPRequest when the health check of WithHealthCheck failed
+var ErrEndpointUnhealthy = errors.New("endpoint unhealthy")
+
+// WithHealthCheck sends a HEAD to checkPath first, resolved against base url or against request url when
+// there is none, and only sends the real request when it gets a status below 400 within timeout.
+// Otherwise ErrEndpointUnhealthy is returned and the real request body is never sent.
+// zero timeout means only caller's context limits the check
+func WithHealthCheck(checkPath string, timeout time.Duration) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.healthCheckPath = checkPath
+		s.healthCheckTimeout = timeout
+	}
+}
+
+// checkHealth does the health check of p for request going to reqURL, if WithHealthCheck was given
+func checkHealth(ctx context.Context, client *http.Client, reqURL string, p *OptReqParams) error {
+	if p.healthCheckPath == "" {
+		return nil
+	}
+
+	base := p.baseURL
+	if base == nil {
+		u, err := url.Parse(reqURL)
+		if err != nil {
+			return err
+		}
+		base = u
+	}
+	ref, err := url.Parse(p.healthCheckPath)
+	if err != nil {
+		return fmt.Errorf("%w: health check path: %v", ErrInvalidOption, err)
+	}
+	checkURL, err := withOrigin(base.ResolveReference(ref).String(), p.origin)
+	if err != nil {
+		return err
+	}
+
+	if p.healthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.healthCheckTimeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, checkURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrEndpointUnhealthy, checkURL, err)
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s: status %d", ErrEndpointUnhealthy, checkURL, res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readTracker records whether anything was read from it
+type readTracker struct {
+	io.Reader
+	read bool
+}
+
+func (r *readTracker) Read(p []byte) (int, error) {
+	r.read = true
+	return r.Reader.Read(p)
+}
+
+// newHealthServer answers health checks on /health with status after delay, other paths are recorded in got
+func newHealthServer(t *testing.T, status int, delay time.Duration) (*countingServer, func() []string) {
+	var mu sync.Mutex
+	var got []string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			if r.Method != http.MethodHead {
+				t.Errorf("health check with %s", r.Method)
+			}
+			time.Sleep(delay)
+			w.WriteHeader(status)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, r.Method+" "+r.URL.Path+" "+string(b))
+		mu.Unlock()
+	})
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+}
+
+func TestHealthCheckUnhealthy(t *testing.T) {
+	for name, tt := range map[string]struct {
+		status int
+		delay  time.Duration
+	}{
+		"error status": {http.StatusServiceUnavailable, 0},
+		"timeout":      {http.StatusOK, 500 * time.Millisecond},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv, got := newHealthServer(t, tt.status, tt.delay)
+			body := &readTracker{Reader: strings.NewReader("payload")}
+			_, err := call(t, srv.URL+"/api", WithMethod(http.MethodPost), WithBody(body),
+				WithHealthCheck("/health", 50*time.Millisecond))
+			if !errors.Is(err, ErrEndpointUnhealthy) {
+				t.Errorf("err = %v, want ErrEndpointUnhealthy", err)
+			}
+			if body.read || len(got()) != 0 {
+				t.Errorf("main request went out, body read %v, server got %q", body.read, got())
+			}
+		})
+	}
+}
+
+func TestHealthCheckHealthy(t *testing.T) {
+	srv, got := newHealthServer(t, http.StatusNoContent, 0)
+	mustCall(t, "api", WithBaseURL(srv.URL+"/v1/"), WithMethod(http.MethodPost), WithRawBody([]byte("payload")),
+		WithHealthCheck("/health", time.Second))
+	if g := got(); len(g) != 1 || g[0] != "POST /v1/api payload" {
+		t.Errorf("server got %q", g)
+	}
+}
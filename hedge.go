@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WithHedgedDelay fires a second identical request when the first one got no response within d and
+// takes whichever response comes first, the other request is cancelled. It trades some extra load for
+// lower tail latency, so it is meant for idempotent requests only. Body is buffered to send it twice
+func WithHedgedDelay(d time.Duration) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.hedgeDelay = d
+	}
+}
+
+// hedgeResult is outcome of one of the hedged requests
+type hedgeResult struct {
+	res *http.Response
+	err error
+	i   int // index of its cancel func
+}
+
+// doAttempt fires req once, hedged if WithHedgedDelay was given
+func doAttempt(ctx context.Context, client *http.Client, req *http.Request, p *OptReqParams) (*http.Response, error) {
+	if p.hedgeDelay <= 0 {
+		return client.Do(req)
+	}
+	return doHedged(ctx, client, req, p.hedgeDelay)
+}
+
+// doHedged fires req and after delay a copy of it, returning the first response. A failed request doesn't
+// win as long as the other one is still in flight
+func doHedged(ctx context.Context, client *http.Client, req *http.Request, delay time.Duration) (*http.Response, error) {
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	fire := func(r *http.Request) {
+		rctx, cancel := context.WithCancel(ctx)
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			res, err := client.Do(r.WithContext(rctx))
+			results <- hedgeResult{res: res, err: err, i: i}
+		}()
+	}
+
+	fire(req)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	inFlight := 1
+	for {
+		select {
+		case <-timer.C:
+			if hedge, ok := cloneWithBody(ctx, req); ok {
+				fire(hedge)
+				inFlight++
+			}
+		case r := <-results:
+			inFlight--
+			if r.err != nil {
+				cancels[r.i]()
+				if inFlight > 0 {
+					continue
+				}
+				return nil, r.err
+			}
+
+			// cancel the loser and clean up after it once it returns
+			for i, cancel := range cancels {
+				if i != r.i {
+					cancel()
+				}
+			}
+			go discardHedgeResults(results, inFlight)
+			cancelOnClose(r.res, cancels[r.i])
+			return r.res, nil
+		}
+	}
+}
+
+// cloneWithBody copies req with a fresh body, false when body can't be read again
+func cloneWithBody(ctx context.Context, req *http.Request) (*http.Request, bool) {
+	c := req.Clone(ctx)
+	if req.GetBody == nil {
+		return c, req.Body == nil || req.Body == http.NoBody
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	c.Body = body
+	return c, true
+}
+
+// discardHedgeResults closes responses of the n requests which lost, they were cancelled already
+func discardHedgeResults(results <-chan hedgeResult, n int) {
+	for ; n > 0; n-- {
+		r := <-results
+		if r.res != nil {
+			_, _ = io.Copy(io.Discard, r.res.Body)
+			_ = r.res.Body.Close()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRandomLatencyServer sleeps slow for 65% of requests and fast for the rest, drawn from a seeded
+// source so the test doesn't flake. Cancelled requests return right away
+func newRandomLatencyServer(t *testing.T, fast, slow time.Duration) *countingServer {
+	var mu sync.Mutex
+	rnd := rand.New(rand.NewPCG(1, 2))
+	return newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		d := fast
+		if rnd.Float64() < 0.65 {
+			d = slow
+		}
+		mu.Unlock()
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// medianLatency makes n calls with opts and returns their median duration
+func medianLatency(t *testing.T, url string, n int, opts ...OptReqParamsOption) time.Duration {
+	t.Helper()
+	var took []time.Duration
+	for range n {
+		start := time.Now()
+		mustCall(t, url, opts...)
+		took = append(took, time.Since(start))
+	}
+	slices.Sort(took)
+	return took[n/2]
+}
+
+func TestHedgedLowersMedianLatency(t *testing.T) {
+	const fast, slow = 5 * time.Millisecond, 100 * time.Millisecond
+	single := medianLatency(t, newRandomLatencyServer(t, fast, slow).URL, 21)
+	hedged := medianLatency(t, newRandomLatencyServer(t, fast, slow).URL, 21, WithHedgedDelay(20*time.Millisecond))
+	if hedged >= single {
+		t.Errorf("hedged median %v, single request median %v", hedged, single)
+	}
+	if hedged >= slow {
+		t.Errorf("hedged median %v is as slow as a slow request", hedged)
+	}
+}
+
+func TestHedgedCancelsLoser(t *testing.T) {
+	var n, cancelled atomic.Int32
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) > 1 {
+			return // hedge answers right away
+		}
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+			cancelled.Add(1)
+		}
+	})
+	start := time.Now()
+	mustCall(t, srv.URL, WithHedgedDelay(20*time.Millisecond))
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("call took %v, hedge should have won", d)
+	}
+	deadline := time.Now().Add(time.Second)
+	for cancelled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n.Load() != 2 || cancelled.Load() != 1 {
+		t.Errorf("server got %d requests, %d cancelled, want 2 and the slow one cancelled", n.Load(), cancelled.Load())
+	}
+}
+
+func TestHedgedNotFiredForFastResponse(t *testing.T) {
+	var n atomic.Int32
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) { n.Add(1) })
+	mustCall(t, srv.URL, WithHedgedDelay(500*time.Millisecond))
+	if got := n.Load(); got != 1 {
+		t.Errorf("server got %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer is a test server which counts connections it accepted
+type countingServer struct {
+	*httptest.Server
+	conns atomic.Int32
+}
+
+// newCountingServer starts server running h, with TLS if useTLS is set. It is closed when the test ends
+func newCountingServer(t *testing.T, useTLS bool, h http.HandlerFunc) *countingServer {
+	t.Helper()
+	srv := &countingServer{Server: httptest.NewUnstartedServer(h)}
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			srv.conns.Add(1)
+		}
+	}
+	if useTLS {
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// call makes a request to url with opts, login is skipped unless opts ask for it
+func call(t *testing.T, url string, opts ...OptReqParamsOption) (*http.Response, error) {
+	t.Helper()
+	p := NewOptReqParams(append([]OptReqParamsOption{WithNoAuth()}, opts...)...)
+	return CustomHTTPRequest(context.Background(), url, "user@example.com", "passwd", p)
+}
+
+// callWithAuth is same as call but leaves auth to opts, for tests of auth modes
+func callWithAuth(t *testing.T, url string, opts ...OptReqParamsOption) (*http.Response, error) {
+	t.Helper()
+	return CustomHTTPRequest(context.Background(), url, "user@example.com", "passwd", NewOptReqParams(opts...))
+}
+
+// mustCall is same as call but fails the test on error, body is read and returned
+func mustCall(t *testing.T, url string, opts ...OptReqParamsOption) (*http.Response, string) {
+	t.Helper()
+	res, err := call(t, url, opts...)
+	if err != nil {
+		t.Fatalf("CustomHTTPRequest: %v", err)
+	}
+	return res, readBody(t, res)
+}
+
+// readBody reads and closes body of res so its connection can be reused
+func readBody(t *testing.T, res *http.Response) string {
+	t.Helper()
+	if res == nil {
+		return ""
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return string(b)
+}
+
+// newClientCert returns PEM encoded self signed client certificate and its key
+func newClientCert(t *testing.T) (certPEM, keyPEM []byte, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, cert
+}
+
+// newMTLSServer starts TLS server which requires client certificate signed by client, answering with its common name
+func newMTLSServer(t *testing.T, client *x509.Certificate) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(client)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serverCAs returns pool trusting certificate of srv
+func serverCAs(srv *httptest.Server) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return pool
+}
+
+// recordingServer is a test server which keeps every request it got along with its body
+type recordingServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	reqs   []*http.Request
+	bodies []string
+}
+
+// newRecordingServer starts recordingServer answering 200 with empty body, closed when the test ends
+func newRecordingServer(t *testing.T) *recordingServer {
+	t.Helper()
+	srv := &recordingServer{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		srv.mu.Lock()
+		// server keeps using r after handler returns, test gets a copy
+		srv.reqs, srv.bodies = append(srv.reqs, r.Clone(context.Background())), append(srv.bodies, string(b))
+		srv.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// last returns the last request and its body, failing the test when there was none
+func (s *recordingServer) last(t *testing.T) (*http.Request, string) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.reqs) == 0 {
+		t.Fatal("server got no request")
+	}
+	return s.reqs[len(s.reqs)-1], s.bodies[len(s.bodies)-1]
+}
+
+// count returns number of requests server got
+func (s *recordingServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.reqs)
+}
+
+// stubLogin replaces MyLoginAPI for the rest of the test with a login giving token, password "bad" fails it
+func stubLogin(t *testing.T, token string) {
+	t.Helper()
+	orig := loginToken
+	loginToken = func(_ context.Context, _, passwd string) (string, error) {
+		if passwd == "bad" {
+			return "", errors.New("wrong password")
+		}
+		return token, nil
+	}
+	t.Cleanup(func() { loginToken = orig })
+}
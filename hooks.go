@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithOnResponse registers fn to be called after the request was fired, for things like metrics or
+// response logging. fn gets nil resp when err is not nil. Multiple calls add more hooks, they run in order
+func WithOnResponse(fn func(resp *http.Response, err error, elapsed time.Duration)) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.onResponse = append(s.onResponse, fn)
+	}
+}
+
+// runOnResponse calls every hook registered with WithOnResponse
+func runOnResponse(p *OptReqParams, resp *http.Response, err error, elapsed time.Duration) {
+	for _, fn := range p.onResponse {
+		fn(resp, err, elapsed)
+	}
+}
+
+// WithOnRetry registers fn to be called before every retry, not before the first attempt. attempt is the
+// retry number starting from 1, req is the request about to be fired and resp and err are what the previous
+// attempt gave. resp body is already drained and closed, only status and headers can be used.
+// Multiple calls add more hooks, they run in order
+func WithOnRetry(fn func(attempt int, req *http.Request, resp *http.Response, err error)) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.onRetry = append(s.onRetry, fn)
+	}
+}
+
+// runOnRetry calls every hook registered with WithOnRetry
+func runOnRetry(p *OptReqParams, attempt int, req *http.Request, resp *http.Response, err error) {
+	for _, fn := range p.onRetry {
+		fn(attempt, req, resp, err)
+	}
+}
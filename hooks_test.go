@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithOnResponse(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+	})
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	var order []string
+	var got []int
+	var elapsed time.Duration
+	hooks := []OptReqParamsOption{
+		WithOnResponse(func(resp *http.Response, err error, d time.Duration) {
+			order = append(order, "first")
+			if err != nil {
+				got = append(got, -1)
+				return
+			}
+			got, elapsed = append(got, resp.StatusCode), d
+		}),
+		WithOnResponse(func(*http.Response, error, time.Duration) { order = append(order, "second") }),
+	}
+	mustCall(t, srv.URL, hooks...)
+	_, _ = call(t, closed.URL, hooks...)
+	if len(got) != 2 || got[0] != http.StatusCreated || got[1] != -1 {
+		t.Errorf("hook got %v, want 201 and then an error", got)
+	}
+	if elapsed < 10*time.Millisecond {
+		t.Errorf("elapsed %v, want at least the server delay", elapsed)
+	}
+	if len(order) != 4 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("hooks ran %q, want in order they were added", order)
+	}
+}
+
+func TestWithOnResponseAfterRetries(t *testing.T) {
+	srv, _ := newRetryAfterServer(t, http.StatusServiceUnavailable, "0")
+	var got []int
+	mustCall(t, srv.URL, WithMaxRetries(1), WithRetryBackoff(time.Millisecond, time.Millisecond, 2),
+		WithOnResponse(func(resp *http.Response, _ error, _ time.Duration) { got = append(got, resp.StatusCode) }))
+	if len(got) != 1 || got[0] != http.StatusOK {
+		t.Errorf("hook got %v, want only the final 200", got)
+	}
+}
+
+func TestWithOnRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	var got []string
+	res, _ := mustCall(t, srv.URL+"/x", WithMaxRetries(3), WithRetryBackoff(time.Millisecond, time.Millisecond, 2),
+		WithOnRetry(func(attempt int, req *http.Request, resp *http.Response, err error) {
+			got = append(got, fmt.Sprintf("%d %s %d %v", attempt, req.URL.Path, resp.StatusCode, err))
+		}))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	want := []string{"1 /x 503 <nil>", "2 /x 503 <nil>"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("hook got %q, want %q", got, want)
+	}
+
+	// first attempt succeeding means no retry to report
+	got = nil
+	mustCall(t, srv.URL, WithMaxRetries(3), WithOnRetry(func(int, *http.Request, *http.Response, error) {
+		got = append(got, "called")
+	}))
+	if len(got) != 0 {
+		t.Errorf("hook called %d times without retries", len(got))
+	}
+}
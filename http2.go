@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// WithHTTP2 configures the transport for HTTP/2 with golang.org/x/net/http2, for services doing better over it
+func WithHTTP2() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.forceHTTP2 = true
+	}
+}
+
+// WithH2C talks HTTP/2 over plain TCP without TLS, for internal or service mesh traffic.
+// It also works for http urls. Dialing options apply, TLS options and proxies can't be used with it
+func WithH2C() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.h2c = true
+	}
+}
+
+// configureHTTP2 turns on HTTP/2 for t
+func configureHTTP2(t *http.Transport) {
+	// only error is when h2 was already registered on t, which is what we want anyway
+	_ = http2.ConfigureTransport(t)
+}
+
+// newH2CTransport returns HTTP/2 transport which dials plain TCP even for TLS connections,
+// using dialing options of p like any other transport
+func newH2CTransport(p *OptReqParams) http.RoundTripper {
+	dial := dialContext(p)
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: defaultDialTimeout}).DialContext
+	}
+	return &http2.Transport{
+		AllowHTTP:          true,
+		DisableCompression: p.disableCompression,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newH2CServer starts plain TCP server speaking HTTP/2 without TLS, replying with protocol of the request
+func newH2CServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestH2C(t *testing.T) {
+	srv := newH2CServer(t)
+	_, body := mustCall(t, srv.URL, WithH2C())
+	if body != "HTTP/2.0" {
+		t.Errorf("server saw %s, want HTTP/2.0", body)
+	}
+}
+
+func TestH2CUsesDialingOptions(t *testing.T) {
+	srv := newH2CServer(t)
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	_, body := mustCall(t, "http://h2c.invalid:"+port, WithH2C(), WithHostOverride("127.0.0.1"))
+	if body != "HTTP/2.0" {
+		t.Errorf("server saw %s, want HTTP/2.0", body)
+	}
+
+	var dials atomic.Int32
+	d := &net.Dialer{ControlContext: func(context.Context, string, string, syscall.RawConn) error {
+		dials.Add(1)
+		return nil
+	}}
+	mustCall(t, srv.URL, WithH2C(), WithCustomDialer(d))
+	if dials.Load() == 0 {
+		t.Error("custom dialer was not used")
+	}
+}
+
+func TestH2CConflicts(t *testing.T) {
+	for name, opt := range map[string]OptReqParamsOption{
+		"transport": WithTransport(http.DefaultTransport),
+		"tls":       WithTLSInsecureSkipVerify(),
+		"http2":     WithHTTP2(),
+		"proxy":     WithProxy("http://proxy.example.com"),
+	} {
+		if _, err := call(t, "http://example.com", WithH2C(), opt); !errors.Is(err, ErrConflictingOptions) {
+			t.Errorf("%s: got %v, want ErrConflictingOptions", name, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultJWTExpiry is how long tokens made by WithJWTClaims are valid when claims have no exp
+const defaultJWTExpiry = 5 * time.Minute
+
+// jwtSigningMethods are the algorithms WithJWTClaims supports
+var jwtSigningMethods = map[string]jwt.SigningMethod{
+	"RS256": jwt.SigningMethodRS256,
+	"ES256": jwt.SigningMethodES256,
+	"HS256": jwt.SigningMethodHS256,
+}
+
+// jwtConfig holds what WithJWTClaims signs tokens with
+type jwtConfig struct {
+	key    crypto.PrivateKey
+	method jwt.SigningMethod
+	claims map[string]any
+}
+
+// WithJWTClaims sends a self signed JWT as bearer token instead of one from MyLoginAPI, for service accounts.
+// alg is RS256, ES256 or HS256, key is an *rsa.PrivateKey, *ecdsa.PrivateKey or []byte secret accordingly.
+// A fresh token is made for every request with iat set to now and exp to five minutes later
+// unless claims have their own exp. Unknown alg is returned by CustomHTTPRequest without making any call
+func WithJWTClaims(privateKey crypto.PrivateKey, alg string, claims map[string]any) OptReqParamsOption {
+	method, ok := jwtSigningMethods[alg]
+	cfg := &jwtConfig{key: privateKey, method: method, claims: maps.Clone(claims)}
+	return func(s *OptReqParams) {
+		if !ok {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: unsupported jwt algorithm %q", ErrInvalidOption, alg))
+			return
+		}
+		s.jwt = cfg
+	}
+}
+
+// token signs a new token out of claims of c
+func (c *jwtConfig) token() (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{"iat": now.Unix()}
+	maps.Copy(claims, c.claims)
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = now.Add(defaultJWTExpiry).Unix()
+	}
+	return jwt.NewWithClaims(c.method, claims).SignedString(c.key)
+}
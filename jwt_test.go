@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// serviceClaims is what tests put in tokens
+type serviceClaims struct {
+	Scope string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// sentToken returns bearer token server got in a call made with opts
+func sentToken(t *testing.T, opts ...OptReqParamsOption) string {
+	t.Helper()
+	srv := newRecordingServer(t)
+	res, err := callWithAuth(t, srv.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	req, _ := srv.last(t)
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		t.Fatalf("Authorization %q", req.Header.Get("Authorization"))
+	}
+	return token
+}
+
+func TestJWTClaims(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("shared secret")
+	tests := []struct {
+		alg      string
+		key, pub any
+	}{
+		{"RS256", rsaKey, &rsaKey.PublicKey},
+		{"ES256", ecKey, &ecKey.PublicKey},
+		{"HS256", secret, secret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			token := sentToken(t, WithJWTClaims(tt.key, tt.alg, map[string]any{"sub": "svc", "scope": "read"}))
+			var claims serviceClaims
+			parsed, err := jwt.ParseWithClaims(token, &claims, func(tok *jwt.Token) (any, error) { return tt.pub, nil },
+				jwt.WithValidMethods([]string{tt.alg}))
+			if err != nil || !parsed.Valid {
+				t.Fatalf("token doesn't verify with public key: %v", err)
+			}
+			if claims.Subject != "svc" || claims.Scope != "read" {
+				t.Errorf("claims %+v", claims)
+			}
+			if claims.IssuedAt == nil || claims.ExpiresAt == nil ||
+				claims.ExpiresAt.Sub(claims.IssuedAt.Time) != defaultJWTExpiry {
+				t.Errorf("iat %v exp %v, want exp five minutes after iat", claims.IssuedAt, claims.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestJWTClaimsOwnExpiry(t *testing.T) {
+	secret := []byte("s")
+	exp := time.Now().Add(time.Hour).Unix()
+	token := sentToken(t, WithJWTClaims(secret, "HS256", map[string]any{"exp": exp}))
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) { return secret, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if claims.ExpiresAt.Unix() != exp {
+		t.Errorf("exp %v, want the one from claims", claims.ExpiresAt)
+	}
+}
+
+func TestJWTClaimsWrongKey(t *testing.T) {
+	token := sentToken(t, WithJWTClaims([]byte("one"), "HS256", nil))
+	_, err := jwt.Parse(token, func(*jwt.Token) (any, error) { return []byte("other"), nil })
+	if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("err = %v, want invalid signature", err)
+	}
+}
+
+func TestJWTClaimsUnsupportedAlg(t *testing.T) {
+	if _, err := callWithAuth(t, "http://example.invalid", WithJWTClaims([]byte("k"), "none", nil)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Logger is a structured log sink for CustomHTTPRequest, keysAndVals are alternating keys and values
+type Logger interface {
+	Info(msg string, keysAndVals ...any)
+	Error(msg string, keysAndVals ...any)
+}
+
+// WithLogger makes CustomHTTPRequest log method, url, status code and latency of every call at Info level
+// and any returned error at Error level. Nothing is logged by default
+func WithLogger(l Logger) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.logger = l
+	}
+}
+
+// NewNoopLogger returns a Logger which drops everything
+func NewNoopLogger() Logger {
+	return noopLogger{}
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
+
+// NewStdLogger returns a Logger writing to stderr through standard log package, every line starts with prefix
+func NewStdLogger(prefix string) Logger {
+	return &stdLogger{l: log.New(os.Stderr, prefix, log.LstdFlags)}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s *stdLogger) Info(msg string, keysAndVals ...any) {
+	s.l.Print("INFO ", formatLogLine(msg, keysAndVals))
+}
+
+func (s *stdLogger) Error(msg string, keysAndVals ...any) {
+	s.l.Print("ERROR ", formatLogLine(msg, keysAndVals))
+}
+
+// formatLogLine renders message followed by key=value pairs
+func formatLogLine(msg string, keysAndVals []any) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndVals); i += 2 {
+		var v any = "(missing)"
+		if i+1 < len(keysAndVals) {
+			v = keysAndVals[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", keysAndVals[i], v)
+	}
+	return b.String()
+}
+
+// LoggerFromSlog bridges a *slog.Logger to Logger
+func LoggerFromSlog(sl *slog.Logger) Logger {
+	return slogLogger{sl: sl}
+}
+
+type slogLogger struct {
+	sl *slog.Logger
+}
+
+func (s slogLogger) Info(msg string, keysAndVals ...any)  { s.sl.Info(msg, keysAndVals...) }
+func (s slogLogger) Error(msg string, keysAndVals ...any) { s.sl.Error(msg, keysAndVals...) }
+
+// logRequest logs outcome of a CustomHTTPRequest call if a logger was given
+func logRequest(l Logger, method, url string, res *http.Response, err error, latency time.Duration) {
+	if l == nil {
+		return
+	}
+	if err != nil {
+		l.Error("http request failed", "method", method, "url", url, "error", err, "latency", latency)
+		return
+	}
+	if res == nil {
+		l.Info("http request", "method", method, "url", url, "status", http.StatusNoContent, "latency", latency)
+		return // empty long poll
+	}
+	l.Info("http request", "method", method, "url", url, "status", res.StatusCode, "latency", latency)
+}
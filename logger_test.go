@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger keeps every entry as level, message and key=value pairs
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) Info(msg string, kv ...any)  { l.add("INFO", msg, kv) }
+func (l *recordingLogger) Error(msg string, kv ...any) { l.add("ERROR", msg, kv) }
+
+func (l *recordingLogger) add(level, msg string, kv []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, level+" "+formatLogLine(msg, kv))
+}
+
+func TestWithLogger(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	l := &recordingLogger{}
+	mustCall(t, srv.URL, WithLogger(l), WithMethod(http.MethodPut))
+	_, _ = call(t, closed.URL, WithLogger(l))
+	if len(l.entries) != 2 {
+		t.Fatalf("logged %q, want 2 entries", l.entries)
+	}
+	if want := "INFO http request method=PUT url=" + srv.URL + " status=202 latency="; !strings.HasPrefix(l.entries[0], want) {
+		t.Errorf("logged %q, want prefix %q", l.entries[0], want)
+	}
+	if want := "ERROR http request failed method=GET url=" + closed.URL + " error="; !strings.HasPrefix(l.entries[1], want) {
+		t.Errorf("logged %q, want prefix %q", l.entries[1], want)
+	}
+}
+
+func TestLoggerFromSlog(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	var buf bytes.Buffer
+	mustCall(t, srv.URL, WithLogger(LoggerFromSlog(slog.New(slog.NewTextHandler(&buf, nil)))))
+	for _, want := range []string{"level=INFO", `msg="http request"`, "method=GET", "url=" + srv.URL, "status=200"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("slog got %q, want it to contain %s", buf.String(), want)
+		}
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger("client ")
+	l.(*stdLogger).l.SetOutput(&buf)
+	l.(*stdLogger).l.SetFlags(0)
+	l.Error("failed", "a", 1, "b")
+	if got := buf.String(); got != "client ERROR failed a=1 b=(missing)\n" {
+		t.Errorf("got %q", got)
+	}
+	// noop logger takes anything without doing anything
+	NewNoopLogger().Error("failed", "a", fmt.Errorf("x"))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// longPollSlack is added to the client timeout of a long poll, so a server answering right at the end
+// of its wait still gets its response through
+const longPollSlack = 5 * time.Second
+
+// WithLongPoll sets up hanging GET style polling where server holds the request until it has something or
+// pollTimeout passes. Server is told how long to wait with Prefer: wait=N, N being pollTimeout rounded up
+// to whole seconds, and timeout is set to N seconds plus longPollSlack.
+// Polls with same settings share one transport which keeps idle connections without time limit, so the
+// next poll reuses the connection of the previous one. A 204 No Content response
+// means nothing happened, CustomHTTPRequest returns nil response and nil error for it
+func WithLongPoll(pollTimeout time.Duration) OptReqParamsOption {
+	wait := max(int((pollTimeout+time.Second-1)/time.Second), 1)
+	return func(s *OptReqParams) {
+		s.longPoll = true
+		s.timeout = time.Duration(wait)*time.Second + longPollSlack
+		WithHeader("Prefer", fmt.Sprintf("wait=%d", wait))(s)
+		WithHeader("Connection", "keep-alive")(s)
+	}
+}
+
+// isEmptyPoll tells whether res is an empty long poll result, its body is closed then
+func isEmptyPoll(res *http.Response, p *OptReqParams) bool {
+	if !p.longPoll || res.StatusCode != http.StatusNoContent {
+		return false
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	return true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLongPollWaitsForResponse(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Prefer"); got != "wait=2" {
+			t.Errorf("Prefer = %q, want wait=2", got)
+		}
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("event"))
+	})
+	res, body := mustCall(t, srv.URL, WithLongPoll(2*time.Second))
+	if res.StatusCode != http.StatusOK || body != "event" {
+		t.Errorf("got %d %q, want 200 event", res.StatusCode, body)
+	}
+}
+
+func TestLongPollNoContentIsEmptyResult(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	res, err := call(t, srv.URL, WithLongPoll(time.Second))
+	if res != nil || err != nil {
+		t.Errorf("got %v, %v, want nil response and nil error", res, err)
+	}
+}
+
+func TestLongPollReusesConnection(t *testing.T) {
+	polls := 0
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls%2 == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, _ = w.Write([]byte("event"))
+	})
+	for range 20 {
+		res, err := call(t, srv.URL, WithLongPoll(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		readBody(t, res)
+	}
+	if n := srv.conns.Load(); n != 1 {
+		t.Errorf("20 polls opened %d connections, want 1", n)
+	}
+}
+
+func TestLongPollWait(t *testing.T) {
+	for _, tc := range []struct {
+		pollTimeout time.Duration
+		want        string
+	}{
+		{2 * time.Second, "wait=2"},
+		{1500 * time.Millisecond, "wait=2"},
+		{300 * time.Millisecond, "wait=1"},
+		{0, "wait=1"},
+	} {
+		p := NewOptReqParams(WithLongPoll(tc.pollTimeout))
+		if got := p.headers.Get("Prefer"); got != tc.want {
+			t.Errorf("%v: Prefer = %q, want %s", tc.pollTimeout, got, tc.want)
+		}
+		if p.timeout <= tc.pollTimeout {
+			t.Errorf("%v: timeout %v, want slack over the poll timeout", tc.pollTimeout, p.timeout)
+		}
+	}
+}
+
+func TestLongPollServerAnsweringAtEndOfWait(t *testing.T) {
+	// server holds the request for all of wait=1, longer than the poll timeout asked for
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		_, _ = w.Write([]byte("event"))
+	})
+	if _, body := mustCall(t, srv.URL, WithLongPoll(200*time.Millisecond)); body != "event" {
+		t.Errorf("got %q, want event", body)
+	}
+}
@@ -4,21 +4,177 @@ package main
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"crypto/tls"
+	"hash"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 )
 
 // OptReqParams contains all optional parameters which are used for valid/invalid request call like invalid token
 // Making use of Function Options pattern to initialize this struct with any number of fields
 type OptReqParams struct {
-	httpMethod      string
-	body            io.Reader
-	useInvalidToken bool
-	queryParam      map[string]string
-	acceptHeader    string
+	httpMethod         string
+	body               io.Reader
+	useInvalidToken    bool
+	queryParam         url.Values
+	acceptHeader       string
+	contentType        string
+	userAgent          string
+	acceptLanguage     string
+	cacheControl       string
+	pragma             string
+	rangeHeader        string
+	cookies            []*http.Cookie
+	headers            http.Header
+	pathParams         map[string]string
+	baseURL            *url.URL
+	fallbackURLs       []*url.URL
+	healthCheckPath    string
+	healthCheckTimeout time.Duration
+	origin             *url.URL // fallback url the request currently goes to, set by doWithFallback
+	timeout            time.Duration
+	latencyStats       LatencyStats // see adaptive.go
+	adaptiveMultiplier float64
+	adaptiveSlack      time.Duration
+	deadline           time.Time
+	mergeStrategy      MergeStrategy
+
+	// body set by other body options, see body.go
+	bodyBytes []byte // body given as bytes, a fresh reader is made for every request
+	bodyKind  string // set by options encoding the body, like json
+	bodyFile  string
+	formBody  url.Values
+
+	bodyEncoding string // compression of request body, gzip or br
+
+	// transfer progress, see progress.go
+	uploadProgress   func(int64, int64)
+	downloadProgress func(int64, int64)
+	progressInterval int64
+
+	// transport and client, see transport.go
+	transport     http.RoundTripper
+	tlsConfig     *tls.Config
+	tlsKey        string            // identifies tls config for ClientPool, options changing tlsConfig extend it
+	tlsBase       *tls.Config       // config given to WithTLSConfig, shared transports are keyed by its identity
+	clientCerts   []tls.Certificate // added by WithMTLS, kept when WithTLSConfig comes after it
+	proxy         func(*http.Request) (*url.URL, error)
+	proxyKey      string // identifies proxy for ClientPool
+	cookieJar     http.CookieJar
+	checkRedirect func(*http.Request, []*http.Request) error
+	clientPool    *ClientPool
+
+	maxIdleConnsPerHost int
+	forceHTTP2          bool
+	h2c                 bool
+	disableKeepAlives   bool
+	keepAliveInterval   time.Duration
+	disableCompression  bool
+	longPoll            bool
+	dialer              *net.Dialer
+	dialerKey           string // identifies dialer of WithLocalAddr for ClientPool, others are compared by identity
+	hostOverride        string
+	dohURL              string
+	preferIPFamily      string // ip4 or ip6
+
+	// authentication, see auth.go
+	noAuth          bool
+	useBasicAuth    bool
+	basicAuthUser   string
+	basicAuthPasswd string
+	useBearerToken  bool
+	bearerToken     string
+	tokenSource     *oauth2Source
+	tokenRefresher  *tokenRefresher
+	useDigestAuth   bool
+	digestUser      string
+	digestPasswd    string
+	sigV4           *sigV4Config
+	jwt             *jwtConfig
+	apiKey          string
+	apiKeyName      string
+	apiKeyInQuery   bool
+
+	// retry settings, see retry.go
+	maxRetries          int
+	retryInitialBackoff time.Duration
+	retryMaxBackoff     time.Duration
+	retryMultiplier     float64
+	retryCondition      func(*http.Response, error) bool
+	hedgeDelay          time.Duration
+	retryAfterRespect   bool
+	maxRetryAfterWait   time.Duration
+	retryBudget         time.Duration
+	onRetry             []func(int, *http.Request, *http.Response, error)
+
+	// throttling and fail fast
+	limiter       *rate.Limiter
+	breaker       *circuitBreaker
+	onStateChange func(State)
+
+	concurrencyLimit int // used by BatchExecute
+	coalesceGroup    *singleflight.Group
+	responseCache    ResponseCache
+	responseCacheTTL time.Duration
+	speculative      *SpeculativeHandle // see speculative.go
+
+	// observability
+	logger               Logger
+	tracerProvider       trace.TracerProvider
+	metrics              *clientMetrics
+	requestIDGen         func() string
+	requestIDHeader      string
+	useIdempotencyKey    bool
+	idempotencyKey       string
+	idempotencyKeyHeader string
+	ifNoneMatch          string
+	ifMatch              string
+	etagStore            ETagStore
+	onResponse           []func(*http.Response, error, time.Duration)
+
+	// request signing and middleware
+	hmacSecret         []byte
+	hmacHeader         string
+	hmacHash           func() hash.Hash
+	requestMiddleware  []RequestMiddleware
+	responseMiddleware []ResponseMiddleware
+
+	// response handling, see response.go
+	maxResponseBodySize    int64
+	strictResponseBodySize bool
+	decoder                ResponseDecoder
+	sseHandler             func(SSEEvent) error
+	bodyTransformers       []func(io.ReadCloser) io.ReadCloser
+	statusValidator        func(int) error
+	errorBodyDecoder       ResponseDecoder
+	errorBodyTarget        any
+
+	// debugging, see dryrun.go and debugdump.go
+	dryRun             bool
+	debugDump          io.Writer
+	debugDumpSensitive bool
+	sensitiveHeaders   []string
+	bodySanitizers     []func([]byte) []byte
+
+	// errors from options which validate their value when created, returned by CustomHTTPRequest
+	optErrs []error
+
+	// names of options applied with NamedOption and ones asked for by WithRequiredOptions, see named.go
+	appliedOptions  []string
+	requiredOptions []string
+
+	// kinds of applied options and pairs declared by ConflictsWith, see dependency.go
+	appliedKinds    []optionKind
+	optionConflicts []optionConflict
+	kindRecorded    bool // set by option being applied when it recorded its own kind, see applyOption
 }
 
 // OptReqParamsOption takes pointer to OptReqParams and modifies some fields in With below
@@ -27,12 +183,16 @@ type OptReqParamsOption func(*OptReqParams)
 // NewOptReqParams takes a slice of option as the rest arguments
 func NewOptReqParams(options ...OptReqParamsOption) *OptReqParams {
 	params := &OptReqParams{}
-	params.httpMethod = http.MethodGet           // default value for http method
-	params.useInvalidToken = false               // default value for invalid token
-	params.acceptHeader = "application/json"     // default value for headers
+	params.httpMethod = http.MethodGet       // default value for http method
+	params.useInvalidToken = false           // default value for invalid token
+	params.acceptHeader = "application/json" // default value for headers
+	params.contentType = ContentTypeJSON     // default value for content type
+	params.retryInitialBackoff = defaultRetryInitialBackoff
+	params.retryMaxBackoff = defaultRetryMaxBackoff
+	params.retryMultiplier = defaultRetryMultiplier
 	for _, o := range options {
 		// Call the option giving the instantiated *OptReqParams as the argument
-		o(params)
+		applyOption(params, o)
 	}
 	// return the modified params instance
 	return params
@@ -52,6 +212,8 @@ func WithBody(body io.Reader) OptReqParamsOption {
 	// another way as above with same functionality
 	f := func(s *OptReqParams) {
 		s.body = body
+		s.bodyBytes = nil // last body option wins
+		s.bodyFile = ""
 	}
 	return f
 }
@@ -62,9 +224,22 @@ func WithUseInvalidToken(useInvalidToken bool) OptReqParamsOption {
 	}
 }
 
+// WithQueryParam adds all given params, it merges with params added before instead of replacing them
 func WithQueryParam(queryParam map[string]string) OptReqParamsOption {
 	return func(s *OptReqParams) {
-		s.queryParam = queryParam
+		for k, v := range queryParam {
+			WithQueryParamSingle(k, v)(s)
+		}
+	}
+}
+
+// WithQueryParamSingle adds one query param, multiple calls accumulate and can be mixed with WithQueryParam
+func WithQueryParamSingle(key, value string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		if s.queryParam == nil {
+			s.queryParam = make(url.Values)
+		}
+		s.queryParam.Add(key, value)
 	}
 }
 
@@ -74,6 +249,14 @@ func WithAcceptHeader(acceptHeader string) OptReqParamsOption {
 	}
 }
 
+// WithTimeout sets a hard deadline on the http.Client itself, independent of caller's context.
+// zero means no timeout which is also the default
+func WithTimeout(d time.Duration) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.timeout = d
+	}
+}
+
 // WithTwoValues is also allowed but not a standard way of doing thing
 func WithTwoValues(acceptHeader string, httpMethod string) OptReqParamsOption {
 	return func(s *OptReqParams) {
@@ -84,47 +267,127 @@ func WithTwoValues(acceptHeader string, httpMethod string) OptReqParamsOption {
 
 // CustomHTTPRequest makes direct call of apis with optional fields required
 func CustomHTTPRequest(ctx context.Context, url, email, passwd string, p *OptReqParams) (*http.Response, error) {
-	var authString string
-	if p.useInvalidToken { // default set to false in constructor NewOptReqParams
-		authString = fmt.Sprintf("Bearer %s", "Invalid Token")
-	} else {
-		// call your login api to get valid token
-		resp, err := MyLoginAPI(ctx, email, passwd)
-		if err != nil {
-			msg := fmt.Sprintf("error in login with user provided credentials %v", err)
-			return nil, errors.New(msg)
+	start := time.Now()
+	ctx, endSpan := startSpan(ctx, p)
+	res, err := customHTTPRequest(ctx, url, email, passwd, p)
+	endSpan(res, err)
+	logRequest(p.logger, p.httpMethod, url, res, err, time.Since(start))
+	return res, err
+}
+
+// customHTTPRequest does the actual work, CustomHTTPRequest only adds instrumentation around it
+func customHTTPRequest(ctx context.Context, url, email, passwd string, p *OptReqParams) (*http.Response, error) {
+	// fail early if any option was given a bad value or options conflict
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	// open circuit breaker fails the call right away without touching the network
+	if p.breaker != nil && !p.dryRun {
+		if err := p.breaker.allow(p.onStateChange); err != nil {
+			return nil, err
 		}
-		authString = fmt.Sprintf("Bearer %s", resp.Token)
+	}
+
+	if p.speculative != nil && !p.dryRun {
+		p.speculative.start(email, passwd)
+	}
+
+	ctx, cancel := deadlineContext(ctx, p)
+	res, err := doWithFallback(ctx, url, email, passwd, p)
+	cancelOnClose(res, cancel) // body has to stay readable after we return
+	if p.breaker != nil && !p.dryRun {
+		p.breaker.record(isFailure(res, err), p.onStateChange)
+	}
+	return res, err
+}
+
+// doRequest builds the request out of p and fires it
+func doRequest(ctx context.Context, url, email, passwd string, p *OptReqParams) (*http.Response, error) {
+	// expand url template and resolve it against base url
+	url, err := resolveURL(url, p)
+	if err != nil {
+		return nil, err
 	}
 
 	// create http req
-	client := http.Client{}
-	req, err := http.NewRequestWithContext(ctx, p.httpMethod, url, p.body)
+	client := newHTTPClient(p)
+	if !p.dryRun {
+		if err := checkHealth(ctx, client, url, p); err != nil {
+			return nil, err
+		}
+	}
+	body, err := requestBody(p)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, p.httpMethod, url, body)
 	if err != nil {
 		return nil, err
 	}
+	if p.bodyFile != "" {
+		closeFile, err := setFileBody(req, p.bodyFile)
+		if err != nil {
+			return nil, err
+		}
+		defer closeFile()
+	}
+	if err := compressBody(req, p); err != nil {
+		return nil, err
+	}
 
-	// add required headers
-	req.Header.Add("Accept", p.acceptHeader)
-	req.Header.Add("Authorization", authString)
-	req.Header.Add("Content-Type", "application/json")
+	// add required headers, authentication goes last so it always wins
+	applyHeaders(req, p)
+	applyRequestID(req, p)
+	applyIdempotencyKey(req, p)
+	applyCookies(req, p)
+	if err := applyAuth(ctx, req, email, passwd, p); err != nil {
+		return nil, err
+	}
 
 	// build query params for request
 	if p.queryParam != nil {
 		q := req.URL.Query()
-		for k := range p.queryParam {
-			q.Add(k, p.queryParam[k])
+		for k, values := range p.queryParam {
+			for _, v := range values {
+				q.Add(k, v)
+			}
 		}
 		req.URL.RawQuery = q.Encode()
 	}
 
-	// fire request
-	res, err := client.Do(req)
+	// conditional headers need final url for cached etags
+	applyConditionalHeaders(req, p)
+	injectTrace(req, p)
+
+	// last chance for caller to change the request
+	req, err = applyRequestMiddleware(req, p)
 	if err != nil {
 		return nil, err
 	}
 
-	return res, err
+	// signing goes after middleware so it covers exactly what is sent
+	if err := signBody(req, p); err != nil {
+		return nil, err
+	}
+	if err := signSigV4(req, p); err != nil {
+		return nil, err
+	}
+
+	if p.dryRun {
+		return nil, &DryRunError{Request: req}
+	}
+
+	// fire request, retrying if asked for
+	dumpRequest(req, p)
+	trackUpload(req, p) // after dump so reading body for it isn't reported
+	res, err := doCached(ctx, client, req, p)
+	if err != nil {
+		return nil, err
+	}
+	dumpResponse(res, p)
+
+	return processResponse(res, p)
 }
 
 func main() {
@@ -137,4 +400,4 @@ func main() {
 	q["age"] = "10"
 	p := NewOptReqParams(WithMethod(http.MethodPost), WithBody(nil), WithQueryParam(q))
 	_, _ = CustomHTTPRequest(context.Background(), "some_url", "email_addr", "email_passwd", p)
-}
\ No newline at end of file
+}
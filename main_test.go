@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// slowHandler answers after d or when client goes away
+func slowHandler(d time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := newCountingServer(t, false, slowHandler(2*time.Second))
+	start := time.Now()
+	_, err := call(t, srv.URL, WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("call took %v with 50ms timeout", d)
+	}
+}
+
+func TestTimeoutLongerThanHandler(t *testing.T) {
+	srv := newCountingServer(t, false, slowHandler(10*time.Millisecond))
+	res, _ := mustCall(t, srv.URL, WithTimeout(5*time.Second))
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status %d", res.StatusCode)
+	}
+}
+
+func TestTimeoutIndependentOfContext(t *testing.T) {
+	srv := newCountingServer(t, false, slowHandler(2*time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	p := NewOptReqParams(WithNoAuth(), WithTimeout(50*time.Millisecond))
+	if _, err := CustomHTTPRequest(ctx, srv.URL, "user@example.com", "passwd", p); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// MergeStrategy tells Merge what to do with header values present in both base and override
+type MergeStrategy int
+
+const (
+	// MergeStrategyOverride replaces base values of a header with override values, it is the default
+	MergeStrategyOverride MergeStrategy = iota
+	// MergeStrategyAppend keeps base values of a header and adds override values after them
+	MergeStrategyAppend
+)
+
+// WithMergeStrategy sets how headers are combined when these params are used as override in Merge
+func WithMergeStrategy(s MergeStrategy) OptReqParamsOption {
+	return func(p *OptReqParams) {
+		p.mergeStrategy = s
+	}
+}
+
+// Merge returns new params made of base with every field set in override on top of it.
+// A field counts as set when it differs from what NewOptReqParams gives, so an override built with
+// NewOptReqParams doesn't reset base back to defaults. Query params and headers are merged per key,
+// keys only in base are kept. An auth mode set in override replaces the one of base.
+// Neither base nor override is modified, nil is same as NewOptReqParams()
+func Merge(base, override *OptReqParams) *OptReqParams {
+	def := NewOptReqParams()
+	if base == nil {
+		base = def
+	}
+	if override == nil {
+		return base.Clone()
+	}
+
+	m := base.Clone()
+	m.httpMethod = pick(m.httpMethod, override.httpMethod, def.httpMethod)
+	m.acceptHeader = pick(m.acceptHeader, override.acceptHeader, def.acceptHeader)
+	m.contentType = pick(m.contentType, override.contentType, def.contentType)
+	m.userAgent = pick(m.userAgent, override.userAgent, def.userAgent)
+	m.acceptLanguage = pick(m.acceptLanguage, override.acceptLanguage, def.acceptLanguage)
+	m.cacheControl = pick(m.cacheControl, override.cacheControl, def.cacheControl)
+	m.pragma = pick(m.pragma, override.pragma, def.pragma)
+	m.rangeHeader = pick(m.rangeHeader, override.rangeHeader, def.rangeHeader)
+	m.timeout = pick(m.timeout, override.timeout, def.timeout)
+	m.useInvalidToken = pick(m.useInvalidToken, override.useInvalidToken, def.useInvalidToken)
+	m.noAuth = pick(m.noAuth, override.noAuth, def.noAuth)
+	m.maxRetries = pick(m.maxRetries, override.maxRetries, def.maxRetries)
+	m.hedgeDelay = pick(m.hedgeDelay, override.hedgeDelay, def.hedgeDelay)
+	m.retryAfterRespect = pick(m.retryAfterRespect, override.retryAfterRespect, def.retryAfterRespect)
+	m.maxRetryAfterWait = pick(m.maxRetryAfterWait, override.maxRetryAfterWait, def.maxRetryAfterWait)
+	m.retryBudget = pick(m.retryBudget, override.retryBudget, def.retryBudget)
+	m.concurrencyLimit = pick(m.concurrencyLimit, override.concurrencyLimit, def.concurrencyLimit)
+	m.requestIDHeader = pick(m.requestIDHeader, override.requestIDHeader, def.requestIDHeader)
+	m.idempotencyKeyHeader = pick(m.idempotencyKeyHeader, override.idempotencyKeyHeader, def.idempotencyKeyHeader)
+	m.ifNoneMatch = pick(m.ifNoneMatch, override.ifNoneMatch, def.ifNoneMatch)
+	m.ifMatch = pick(m.ifMatch, override.ifMatch, def.ifMatch)
+	m.dryRun = pick(m.dryRun, override.dryRun, def.dryRun)
+	m.debugDumpSensitive = pick(m.debugDumpSensitive, override.debugDumpSensitive, def.debugDumpSensitive)
+	m.mergeStrategy = pick(m.mergeStrategy, override.mergeStrategy, def.mergeStrategy)
+	if override.maxResponseBodySize != def.maxResponseBodySize {
+		m.maxResponseBodySize, m.strictResponseBodySize = override.maxResponseBodySize, override.strictResponseBodySize
+	}
+	if !override.deadline.IsZero() {
+		m.deadline = override.deadline
+	}
+	if override.body != nil || override.bodyBytes != nil || override.bodyFile != "" {
+		m.body, m.bodyBytes, m.bodyFile = override.body, override.bodyBytes, override.bodyFile
+		m.bodyKind, m.formBody = override.bodyKind, cloneValues(override.formBody)
+	}
+	if override.transport != nil {
+		m.transport = override.transport
+	}
+	if override.tlsConfig != nil {
+		m.tlsConfig, m.tlsBase, m.tlsKey = override.tlsConfig, override.tlsBase, override.tlsKey
+		m.clientCerts = override.clientCerts
+	}
+	if override.cookieJar != nil {
+		m.cookieJar = override.cookieJar
+	}
+	if override.checkRedirect != nil {
+		m.checkRedirect = override.checkRedirect
+	}
+	if override.proxy != nil {
+		m.proxy, m.proxyKey = override.proxy, override.proxyKey
+	}
+	m.hostOverride = pick(m.hostOverride, override.hostOverride, def.hostOverride)
+	m.dohURL = pick(m.dohURL, override.dohURL, def.dohURL)
+	m.preferIPFamily = pick(m.preferIPFamily, override.preferIPFamily, def.preferIPFamily)
+	if override.dialer != nil {
+		m.dialer, m.dialerKey = override.dialer, override.dialerKey
+	}
+	if override.clientPool != nil {
+		m.clientPool = override.clientPool
+	}
+	m.maxIdleConnsPerHost = pick(m.maxIdleConnsPerHost, override.maxIdleConnsPerHost, def.maxIdleConnsPerHost)
+	m.forceHTTP2 = pick(m.forceHTTP2, override.forceHTTP2, def.forceHTTP2)
+	m.h2c = pick(m.h2c, override.h2c, def.h2c)
+	m.disableKeepAlives = pick(m.disableKeepAlives, override.disableKeepAlives, def.disableKeepAlives)
+	m.keepAliveInterval = pick(m.keepAliveInterval, override.keepAliveInterval, def.keepAliveInterval)
+	m.disableCompression = pick(m.disableCompression, override.disableCompression, def.disableCompression)
+	m.longPoll = pick(m.longPoll, override.longPoll, def.longPoll)
+	m.bodyEncoding = pick(m.bodyEncoding, override.bodyEncoding, def.bodyEncoding)
+	m.progressInterval = pick(m.progressInterval, override.progressInterval, def.progressInterval)
+	if override.uploadProgress != nil {
+		m.uploadProgress = override.uploadProgress
+	}
+	if override.downloadProgress != nil {
+		m.downloadProgress = override.downloadProgress
+	}
+	if override.healthCheckPath != "" {
+		m.healthCheckPath, m.healthCheckTimeout = override.healthCheckPath, override.healthCheckTimeout
+	}
+	if override.baseURL != nil {
+		u := *override.baseURL
+		m.baseURL = &u
+	}
+	if override.latencyStats != nil {
+		m.latencyStats = override.latencyStats
+	}
+	m.adaptiveMultiplier = pick(m.adaptiveMultiplier, override.adaptiveMultiplier, def.adaptiveMultiplier)
+	m.adaptiveSlack = pick(m.adaptiveSlack, override.adaptiveSlack, def.adaptiveSlack)
+	if override.limiter != nil {
+		m.limiter = override.limiter
+	}
+	if override.breaker != nil {
+		m.breaker = override.breaker
+	}
+	if override.onStateChange != nil {
+		m.onStateChange = override.onStateChange
+	}
+	if override.responseCache != nil {
+		m.responseCache, m.responseCacheTTL = override.responseCache, override.responseCacheTTL
+	}
+	if override.coalesceGroup != nil {
+		m.coalesceGroup = override.coalesceGroup
+	}
+	if override.speculative != nil {
+		m.speculative = override.speculative
+	}
+	if override.logger != nil {
+		m.logger = override.logger
+	}
+	if override.tracerProvider != nil {
+		m.tracerProvider = override.tracerProvider
+	}
+	if override.metrics != nil {
+		m.metrics = override.metrics
+	}
+	if override.requestIDGen != nil {
+		m.requestIDGen = override.requestIDGen
+	}
+	if override.etagStore != nil {
+		m.etagStore = override.etagStore
+	}
+	if override.sseHandler != nil {
+		m.sseHandler = override.sseHandler
+	}
+	if override.decoder != nil {
+		m.decoder = override.decoder
+	}
+	if override.statusValidator != nil {
+		m.statusValidator = override.statusValidator
+	}
+	if override.errorBodyDecoder != nil {
+		m.errorBodyDecoder, m.errorBodyTarget = override.errorBodyDecoder, override.errorBodyTarget
+	}
+	if override.debugDump != nil {
+		m.debugDump = override.debugDump
+	}
+	if override.retryCondition != nil {
+		m.retryCondition = override.retryCondition
+	}
+
+	// fields which only make sense together are taken as a group
+	if len(override.authModes()) > 0 {
+		m.clearAuthModes() // auth mode of override replaces the one of base instead of conflicting with it
+	}
+	if override.useBasicAuth {
+		m.useBasicAuth, m.basicAuthUser, m.basicAuthPasswd = true, override.basicAuthUser, override.basicAuthPasswd
+	}
+	if override.tokenSource != nil {
+		m.tokenSource = override.tokenSource
+	}
+	if override.tokenRefresher != nil {
+		m.tokenRefresher = override.tokenRefresher
+	}
+	if override.useDigestAuth {
+		m.useDigestAuth, m.digestUser, m.digestPasswd = true, override.digestUser, override.digestPasswd
+	}
+	if override.sigV4 != nil {
+		m.sigV4 = override.sigV4
+	}
+	if override.apiKey != "" {
+		m.apiKey, m.apiKeyName, m.apiKeyInQuery = override.apiKey, override.apiKeyName, override.apiKeyInQuery
+	}
+	if override.jwt != nil {
+		m.jwt = override.jwt
+	}
+	if override.hmacSecret != nil {
+		m.hmacSecret, m.hmacHeader, m.hmacHash = override.hmacSecret, override.hmacHeader, override.hmacHash
+	}
+	if override.useIdempotencyKey {
+		m.useIdempotencyKey, m.idempotencyKey = true, override.idempotencyKey
+	}
+	if override.useBearerToken {
+		m.useBearerToken, m.bearerToken = true, override.bearerToken
+	}
+	if override.retryInitialBackoff != def.retryInitialBackoff || override.retryMaxBackoff != def.retryMaxBackoff ||
+		override.retryMultiplier != def.retryMultiplier {
+		m.retryInitialBackoff, m.retryMaxBackoff, m.retryMultiplier =
+			override.retryInitialBackoff, override.retryMaxBackoff, override.retryMultiplier
+	}
+
+	// composite fields are merged per key
+	if override.queryParam != nil && m.queryParam == nil {
+		m.queryParam = make(url.Values)
+	}
+	for k, values := range override.queryParam {
+		m.queryParam[k] = slices.Clone(values)
+	}
+	if override.headers != nil && m.headers == nil {
+		m.headers = make(http.Header)
+	}
+	for k, values := range override.headers {
+		if override.mergeStrategy == MergeStrategyAppend {
+			m.headers[k] = append(m.headers[k], values...) // m.headers is already a copy of base
+		} else {
+			m.headers[k] = slices.Clone(values)
+		}
+	}
+	if override.pathParams != nil {
+		if m.pathParams == nil {
+			m.pathParams = make(map[string]string)
+		}
+		maps.Copy(m.pathParams, override.pathParams)
+	}
+	m.optErrs = append(m.optErrs, override.optErrs...)
+	m.appliedOptions = append(m.appliedOptions, override.appliedOptions...)
+	m.requiredOptions = append(m.requiredOptions, override.requiredOptions...)
+	m.appliedKinds = append(m.appliedKinds, override.appliedKinds...)
+	m.optionConflicts = append(m.optionConflicts, override.optionConflicts...)
+	m.fallbackURLs = append(m.fallbackURLs, override.fallbackURLs...)
+	m.cookies = append(m.cookies, override.cookies...)
+	m.onResponse = append(m.onResponse, override.onResponse...) // hooks of both run, base ones first
+	m.onRetry = append(m.onRetry, override.onRetry...)
+	m.requestMiddleware = append(m.requestMiddleware, override.requestMiddleware...)
+	m.responseMiddleware = append(m.responseMiddleware, override.responseMiddleware...)
+	m.bodyTransformers = append(m.bodyTransformers, override.bodyTransformers...)
+	m.sensitiveHeaders = append(m.sensitiveHeaders, override.sensitiveHeaders...)
+	m.bodySanitizers = append(m.bodySanitizers, override.bodySanitizers...)
+
+	return m
+}
+
+// clearAuthModes unsets every explicit auth mode of p
+func (p *OptReqParams) clearAuthModes() {
+	p.useBasicAuth, p.basicAuthUser, p.basicAuthPasswd = false, "", ""
+	p.useBearerToken, p.bearerToken = false, ""
+	p.useDigestAuth, p.digestUser, p.digestPasswd = false, "", ""
+	p.tokenSource, p.tokenRefresher, p.sigV4, p.jwt = nil, nil, nil, nil
+	p.apiKey, p.apiKeyName, p.apiKeyInQuery = "", "", false
+}
+
+// pick returns override when it was changed from its default value, otherwise base
+func pick[T comparable](base, override, def T) T {
+	if override != def {
+		return override
+	}
+	return base
+}
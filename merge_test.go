@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestMergeStrategy(t *testing.T) {
+	srv := newRecordingServer(t)
+	base := NewOptReqParams(WithNoAuth(), WithHeader("X-Tag", "base"), WithHeader("X-Base", "1"),
+		WithQueryParam(map[string]string{"tag": "base", "page": "1"}))
+	for _, tc := range []struct {
+		name     string
+		strategy MergeStrategy
+		tags     []string
+	}{
+		{"override", MergeStrategyOverride, []string{"override"}},
+		{"append", MergeStrategyAppend, []string{"base", "override"}},
+	} {
+		override := NewOptReqParams(WithMergeStrategy(tc.strategy), WithHeader("X-Tag", "override"),
+			WithQueryParam(map[string]string{"tag": "override"}))
+		res, err := CustomHTTPRequest(context.Background(), srv.URL, "", "", Merge(base, override))
+		if err != nil {
+			t.Fatal(err)
+		}
+		readBody(t, res)
+		r, _ := srv.last(t)
+		if got := r.Header.Values("X-Tag"); !slices.Equal(got, tc.tags) {
+			t.Errorf("%s: X-Tag %q, want %q", tc.name, got, tc.tags)
+		}
+		if got := r.Header.Get("X-Base"); got != "1" {
+			t.Errorf("%s: X-Base %q, want header only in base kept", tc.name, got)
+		}
+		// strategy is for headers only, query values of a key in override always replace the base ones
+		if q := r.URL.Query(); !slices.Equal(q["tag"], []string{"override"}) || q.Get("page") != "1" {
+			t.Errorf("%s: query %q, want tag of override and page of base", tc.name, r.URL.RawQuery)
+		}
+	}
+	if got := base.headers.Values("X-Tag"); !slices.Equal(got, []string{"base"}) {
+		t.Errorf("base X-Tag changed to %q by Merge", got)
+	}
+}
+
+func TestMergeStrategyOfBaseIsIgnored(t *testing.T) {
+	// strategy belongs to the override, base asking for append doesn't make an override append
+	base := NewOptReqParams(WithMergeStrategy(MergeStrategyAppend), WithHeader("X-Tag", "base"))
+	m := Merge(base, NewOptReqParams(WithHeader("X-Tag", "override")))
+	if got := m.headers.Values("X-Tag"); !slices.Equal(got, []string{"override"}) {
+		t.Errorf("X-Tag %q, want override", got)
+	}
+}
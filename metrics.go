@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// clientMetrics are the collectors WithPrometheusMetrics records into
+type clientMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// WithPrometheusMetrics records duration of every request in http_client_request_duration_seconds histogram
+// labeled by method, host and status_code, and failed requests in http_client_errors_total counter labeled
+// by method and host. Collectors are registered in reg only once, options made with the same reg share them.
+// nil reg means prometheus.DefaultRegisterer
+func WithPrometheusMetrics(reg prometheus.Registerer) OptReqParamsOption {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m, err := registerClientMetrics(reg)
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: prometheus metrics: %v", ErrInvalidOption, err))
+			return
+		}
+		s.metrics = m
+	}
+}
+
+// registerClientMetrics registers collectors in reg, taking the existing ones if they were registered before
+func registerClientMetrics(reg prometheus.Registerer) (*clientMetrics, error) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_request_duration_seconds",
+		Help:    "Duration of HTTP requests made by CustomHTTPRequest.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "host", "status_code"})
+	errorsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_client_errors_total",
+		Help: "HTTP requests made by CustomHTTPRequest which failed without a response.",
+	}, []string{"method", "host"})
+
+	duration, err := registerOnce(reg, duration)
+	if err != nil {
+		return nil, err
+	}
+	errorsTotal, err = registerOnce(reg, errorsTotal)
+	if err != nil {
+		return nil, err
+	}
+	return &clientMetrics{duration: duration, errors: errorsTotal}, nil
+}
+
+// registerOnce registers c in reg, or returns collector registered before in its place
+func registerOnce[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	err := reg.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+// recordMetrics records outcome of req if WithPrometheusMetrics was given
+func recordMetrics(p *OptReqParams, req *http.Request, res *http.Response, err error, elapsed time.Duration) {
+	if p.metrics == nil {
+		return
+	}
+	if err != nil {
+		p.metrics.errors.WithLabelValues(req.Method, req.URL.Host).Inc()
+		return
+	}
+	p.metrics.duration.WithLabelValues(req.Method, req.URL.Host, strconv.Itoa(res.StatusCode)).
+		Observe(elapsed.Seconds())
+}
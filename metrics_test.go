@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// histogramCount returns sample count of duration histogram with given labels, zero if there is none
+func histogramCount(t *testing.T, reg *prometheus.Registry, labels map[string]string) uint64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "http_client_request_duration_seconds" {
+			continue
+		}
+	metrics:
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	host := srv.Listener.Addr().String()
+	reg := prometheus.NewRegistry()
+	metrics := WithPrometheusMetrics(reg)
+
+	mustCall(t, srv.URL, metrics)
+	mustCall(t, srv.URL, metrics)
+	mustCall(t, srv.URL+"/fail", metrics, WithMethod(http.MethodPost))
+
+	if n := testutil.CollectAndCount(reg, "http_client_request_duration_seconds"); n != 2 {
+		t.Errorf("%d duration series, want 2", n)
+	}
+	if n := histogramCount(t, reg, map[string]string{"method": "GET", "host": host, "status_code": "200"}); n != 2 {
+		t.Errorf("GET 200 observed %d times, want 2", n)
+	}
+	if n := histogramCount(t, reg, map[string]string{"method": "POST", "host": host, "status_code": "500"}); n != 1 {
+		t.Errorf("POST 500 observed %d times, want 1", n)
+	}
+	if n := testutil.CollectAndCount(reg, "http_client_errors_total"); n != 0 {
+		t.Errorf("%d error series after calls which got a response", n)
+	}
+}
+
+func TestPrometheusMetricsErrors(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	srv.Close()
+	reg := prometheus.NewRegistry()
+	if _, err := call(t, srv.URL, WithPrometheusMetrics(reg)); err == nil {
+		t.Fatal("call to closed server succeeded")
+	}
+	m, err := registerClientMetrics(reg) // gives back the collectors registered by the option
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(srv.URL)
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("GET", u.Host)); got != 1 {
+		t.Errorf("errors total %v, want 1", got)
+	}
+}
+
+func TestPrometheusMetricsConflictingCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "http_client_request_duration_seconds"}))
+	if _, err := call(t, "http://example.invalid", WithPrometheusMetrics(reg)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "net/http"
+
+// RequestMiddleware gets the fully built request just before it is sent and returns the request to send,
+// for things like signing or a fresh short lived token. Returning an error aborts the call
+type RequestMiddleware func(*http.Request) (*http.Request, error)
+
+// WithRequestMiddleware adds fn to the request middleware chain, multiple calls compose and run in order
+func WithRequestMiddleware(fn func(*http.Request) (*http.Request, error)) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.requestMiddleware = append(s.requestMiddleware, fn)
+	}
+}
+
+// applyRequestMiddleware runs req through the request middleware chain of p
+func applyRequestMiddleware(req *http.Request, p *OptReqParams) (*http.Request, error) {
+	for _, mw := range p.requestMiddleware {
+		var err error
+		if req, err = mw(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// ResponseMiddleware gets the response right after it was received and returns the response to give to caller.
+// It may read and replace the body, for example to unwrap an envelope. Returning an error fails the call
+type ResponseMiddleware func(*http.Response) (*http.Response, error)
+
+// WithResponseMiddleware adds fn to the response middleware chain, multiple calls compose and run in order
+func WithResponseMiddleware(fn func(*http.Response) (*http.Response, error)) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.responseMiddleware = append(s.responseMiddleware, fn)
+	}
+}
+
+// applyResponseMiddleware runs res through the response middleware chain of p.
+// on error the body of last response is closed since caller would never get it
+func applyResponseMiddleware(res *http.Response, p *OptReqParams) (*http.Response, error) {
+	for _, mw := range p.responseMiddleware {
+		next, err := mw(res)
+		if err != nil {
+			if next == nil {
+				next = res
+			}
+			_ = next.Body.Close()
+			return nil, err
+		}
+		res = next
+	}
+	return res, nil
+}
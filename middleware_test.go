@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRequestMiddlewareOrder(t *testing.T) {
+	srv := newRecordingServer(t)
+	var order []string
+	mw := func(name string) func(*http.Request) (*http.Request, error) {
+		return func(r *http.Request) (*http.Request, error) {
+			order = append(order, name)
+			r.Header.Add("X-Chain", name)
+			return r, nil
+		}
+	}
+	mustCall(t, srv.URL, WithRequestMiddleware(mw("first")), WithRequestMiddleware(mw("second")),
+		WithRequestMiddleware(mw("third")))
+
+	want := []string{"first", "second", "third"}
+	if !slices.Equal(order, want) {
+		t.Errorf("middleware ran in order %q, want %q", order, want)
+	}
+	req, _ := srv.last(t)
+	if got := req.Header.Values("X-Chain"); !slices.Equal(got, want) {
+		t.Errorf("server got X-Chain %q, want %q", got, want)
+	}
+}
+
+func TestRequestMiddlewareReplacesRequest(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithRequestMiddleware(func(r *http.Request) (*http.Request, error) {
+		c := r.Clone(r.Context())
+		c.URL.Path = "/rewritten"
+		return c, nil
+	}))
+	if req, _ := srv.last(t); req.URL.Path != "/rewritten" {
+		t.Errorf("server got path %q", req.URL.Path)
+	}
+}
+
+func TestRequestMiddlewareError(t *testing.T) {
+	srv := newRecordingServer(t)
+	mwErr := errors.New("denied")
+	called := false
+	_, err := call(t, srv.URL,
+		WithRequestMiddleware(func(r *http.Request) (*http.Request, error) { return nil, mwErr }),
+		WithRequestMiddleware(func(r *http.Request) (*http.Request, error) { called = true; return r, nil }))
+	if !errors.Is(err, mwErr) {
+		t.Errorf("err = %v, want middleware error", err)
+	}
+	if called || srv.count() != 0 {
+		t.Errorf("call went on after middleware failed, next middleware called %v, %d requests", called, srv.count())
+	}
+}
+
+func TestResponseMiddlewareReplacesBody(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"data":"payload"}`)
+	})
+	unwrap := func(res *http.Response) (*http.Response, error) {
+		var envelope struct{ Data string }
+		err := json.NewDecoder(res.Body).Decode(&envelope)
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = io.NopCloser(strings.NewReader(envelope.Data))
+		return res, nil
+	}
+	upper := func(res *http.Response) (*http.Response, error) {
+		b, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		res.Body = io.NopCloser(strings.NewReader(strings.ToUpper(string(b))))
+		return res, err
+	}
+	if _, body := mustCall(t, srv.URL, WithResponseMiddleware(unwrap), WithResponseMiddleware(upper)); body != "PAYLOAD" {
+		t.Errorf("caller read %q, want body replaced by both middlewares in order", body)
+	}
+}
+
+func TestResponseMiddlewareErrorClosesBody(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	mwErr := errors.New("bad response")
+	var body *closeTracker
+	res, err := call(t, srv.URL,
+		WithResponseMiddleware(func(res *http.Response) (*http.Response, error) {
+			body = &closeTracker{ReadCloser: res.Body}
+			res.Body = body
+			return res, nil
+		}),
+		WithResponseMiddleware(func(res *http.Response) (*http.Response, error) { return nil, mwErr }))
+	if !errors.Is(err, mwErr) || res != nil {
+		t.Fatalf("got %v, %v, want middleware error", res, err)
+	}
+	if !body.closed {
+		t.Error("body of failed response was not closed")
+	}
+}
+
+// closeTracker records whether it was closed
+type closeTracker struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrMissingRequiredOption is returned by CustomHTTPRequest when an option named in WithRequiredOptions
+// wasn't applied, error message lists the missing names
+var ErrMissingRequiredOption = errors.New("missing required option")
+
+// NamedOption applies opt and records name as applied, so WithRequiredOptions can check for it.
+// name is also the kind of the option for ConflictsWith, in place of the constructor of opt
+func NamedOption(name string, opt OptReqParamsOption) OptReqParamsOption {
+	named := withKind(optionKind(fmt.Sprintf("NamedOption(%q)", name)), opt)
+	return func(s *OptReqParams) {
+		named(s)
+		s.appliedOptions = append(s.appliedOptions, name)
+	}
+}
+
+// WithRequiredOptions makes CustomHTTPRequest fail without making any call unless every option in names was
+// applied through NamedOption, before or after this option
+func WithRequiredOptions(names ...string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.requiredOptions = append(s.requiredOptions, names...)
+	}
+}
+
+// missingRequiredOptions returns error listing required names of p which were never applied, nil if none
+func (p *OptReqParams) missingRequiredOptions() error {
+	var missing []string
+	for _, name := range p.requiredOptions {
+		if !slices.Contains(p.appliedOptions, name) && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrMissingRequiredOption, strings.Join(missing, ", "))
+}
+
+// OptionMeta describes an option for generated documentation, see NamedWithMeta
+type OptionMeta struct {
+	Name, Description string
+	AddedInVersion    string
+}
+
+// optionMetas holds metadata registered by NamedWithMeta, by name
+var (
+	optionMetasMu sync.RWMutex
+	optionMetas   = map[string]OptionMeta{}
+)
+
+// NamedWithMeta is same as NamedOption with meta.Name, and also registers meta so it is listed by
+// RegisteredOptions. registering same name again replaces its metadata
+func NamedWithMeta(meta OptionMeta, opt OptReqParamsOption) OptReqParamsOption {
+	optionMetasMu.Lock()
+	optionMetas[meta.Name] = meta
+	optionMetasMu.Unlock()
+	return NamedOption(meta.Name, opt)
+}
+
+// RegisteredOptions returns metadata of every option made with NamedWithMeta, sorted by name
+func RegisteredOptions() []OptionMeta {
+	optionMetasMu.RLock()
+	defer optionMetasMu.RUnlock()
+	return slices.SortedFunc(maps.Values(optionMetas), func(a, b OptionMeta) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// GenerateMarkdown writes a Markdown table of RegisteredOptions to w
+func GenerateMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Option | Description | Added in |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, m := range RegisteredOptions() {
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", m.Name, markdownCell(m.Description), markdownCell(m.AddedInVersion))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes text so it stays within one table cell
+var markdownCell = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequiredOptions(t *testing.T) {
+	srv := newRecordingServer(t)
+	timeout := NamedOption("timeout", WithTimeout(time.Second))
+	ua := NamedOption("user-agent", WithUserAgent("svc/1.0"))
+
+	for _, tc := range []struct {
+		name    string
+		opts    []OptReqParamsOption
+		missing string
+	}{
+		{"all applied", []OptReqParamsOption{WithRequiredOptions("timeout", "user-agent"), timeout, ua}, ""},
+		{"required after named", []OptReqParamsOption{timeout, ua, WithRequiredOptions("timeout", "user-agent")}, ""},
+		{"none applied", []OptReqParamsOption{WithRequiredOptions("timeout", "user-agent")}, "timeout, user-agent"},
+		{"one missing", []OptReqParamsOption{WithRequiredOptions("timeout"), WithRequiredOptions("user-agent"), ua},
+			"timeout"},
+		{"unnamed doesn't count", []OptReqParamsOption{WithRequiredOptions("timeout"), WithTimeout(time.Second)},
+			"timeout"},
+		{"listed once", []OptReqParamsOption{WithRequiredOptions("timeout", "timeout")}, "timeout"},
+	} {
+		before := srv.count()
+		res, err := call(t, srv.URL, tc.opts...)
+		if tc.missing == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			readBody(t, res)
+			continue
+		}
+		if !errors.Is(err, ErrMissingRequiredOption) {
+			t.Errorf("%s: error %v, want ErrMissingRequiredOption", tc.name, err)
+		} else if !strings.HasSuffix(err.Error(), ": "+tc.missing) {
+			t.Errorf("%s: error %q doesn't list %q", tc.name, err, tc.missing)
+		}
+		if srv.count() != before {
+			t.Errorf("%s: request was sent", tc.name)
+		}
+	}
+}
+
+func TestNamedOptionApplies(t *testing.T) {
+	p := NewOptReqParams(NamedOption("timeout", WithTimeout(time.Second)))
+	if p.timeout != time.Second {
+		t.Errorf("timeout %v, want wrapped option applied", p.timeout)
+	}
+	if err := p.With(WithRequiredOptions("timeout")).Validate(); err != nil {
+		t.Errorf("derived params lost applied names: %v", err)
+	}
+}
+
+func TestNamedWithMeta(t *testing.T) {
+	metas := []OptionMeta{
+		{Name: "test-meta-timeout", Description: "short timeout for internal calls", AddedInVersion: "v1.2.0"},
+		{Name: "test-meta-agent", Description: "user agent | with pipe\nand newline", AddedInVersion: "v1.3.0"},
+	}
+	opt := NamedWithMeta(metas[0], WithTimeout(time.Second))
+	NamedWithMeta(metas[1], WithUserAgent("svc/1.0"))
+
+	p := NewOptReqParams(WithRequiredOptions("test-meta-timeout"), opt)
+	if err := p.Validate(); err != nil || p.timeout != time.Second {
+		t.Errorf("option not applied as named: timeout %v, %v", p.timeout, err)
+	}
+
+	got := map[string]OptionMeta{}
+	var names []string
+	for _, m := range RegisteredOptions() {
+		got[m.Name] = m
+		names = append(names, m.Name)
+	}
+	for _, m := range metas {
+		if got[m.Name] != m {
+			t.Errorf("registered %+v, want %+v", got[m.Name], m)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("RegisteredOptions not sorted: %q", names)
+	}
+
+	// registering same name again replaces metadata
+	updated := OptionMeta{Name: "test-meta-timeout", Description: "updated", AddedInVersion: "v1.2.0"}
+	NamedWithMeta(updated, WithTimeout(time.Second))
+	for _, m := range RegisteredOptions() {
+		if m.Name == updated.Name && m != updated {
+			t.Errorf("metadata %+v, want replaced by %+v", m, updated)
+		}
+	}
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	NamedWithMeta(OptionMeta{Name: "test-md", Description: "a | b\nc", AddedInVersion: "v2.0.0"}, WithNoAuth())
+	var b strings.Builder
+	if err := GenerateMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if lines[0] != "| Option | Description | Added in |" || lines[1] != "| --- | --- | --- |" {
+		t.Errorf("table header %q", lines[:2])
+	}
+	if want := "| `test-md` | a \\| b c | v2.0.0 |"; !slices.Contains(lines, want) {
+		t.Errorf("table has no row %q:\n%s", want, b.String())
+	}
+}
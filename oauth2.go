@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// WithOAuth2ClientCredentials gets bearer token from tokenURL with OAuth 2.0 client credentials grant instead of
+// MyLoginAPI. Token is cached and fetched again automatically when it is about to expire,
+// clones share the cache. Token is fetched with context of the call and same client as the call itself,
+// so WithTimeout, TLS, proxy and dialer options apply to the token endpoint too
+func WithOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes []string) OptReqParamsOption {
+	src := &oauth2Source{cfg: &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+		Scopes:       scopes,
+	}}
+	return func(s *OptReqParams) {
+		s.tokenSource = src
+	}
+}
+
+// oauth2Source caches token of a client credentials config, safe for concurrent use
+type oauth2Source struct {
+	cfg *clientcredentials.Config
+
+	mu  sync.Mutex // held while fetching so concurrent calls wait for one fetch
+	tok *oauth2.Token
+}
+
+// token returns access token, fetching a new one with ctx and client only when cached one expired
+func (o *oauth2Source) token(ctx context.Context, client *http.Client) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// token source of config takes client from the context it is made with
+	fetch := o.cfg.TokenSource(context.WithValue(ctx, oauth2.HTTPClient, client))
+	tok, err := oauth2.ReuseTokenSource(o.tok, fetch).Token()
+	if err != nil {
+		return "", err
+	}
+	o.tok = tok
+	return tok.AccessToken, nil
+}
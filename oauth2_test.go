@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTokenServer simulates a client credentials token endpoint, tokens are numbered by fetch
+func newTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if err := r.ParseForm(); err != nil || r.Method != http.MethodPost ||
+			r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("scope") != "read write" {
+			t.Errorf("bad token request %s %v", r.Method, r.PostForm)
+		}
+		if id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		n := fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func oauth2Calls(t *testing.T, api *recordingServer, opt OptReqParamsOption, n int) []string {
+	t.Helper()
+	var auth []string
+	for range n {
+		res, err := callWithAuth(t, api.URL, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readBody(t, res)
+		req, _ := api.last(t)
+		auth = append(auth, req.Header.Get("Authorization"))
+	}
+	return auth
+}
+
+func TestOAuth2TokenCached(t *testing.T) {
+	tokens, fetches := newTokenServer(t, 3600)
+	api := newRecordingServer(t)
+	opt := WithOAuth2ClientCredentials(tokens.URL, "client", "secret", []string{"read", "write"})
+	for i, auth := range oauth2Calls(t, api, opt, 3) {
+		if auth != "Bearer tok-1" {
+			t.Errorf("call %d sent %q", i, auth)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("token fetched %d times, want 1", n)
+	}
+}
+
+func TestOAuth2TokenRefetchedWhenExpired(t *testing.T) {
+	tokens, fetches := newTokenServer(t, 1) // expires within expiry delta of oauth2, so it's never reused
+	api := newRecordingServer(t)
+	opt := WithOAuth2ClientCredentials(tokens.URL, "client", "secret", []string{"read", "write"})
+	if auth := oauth2Calls(t, api, opt, 2); auth[0] != "Bearer tok-1" || auth[1] != "Bearer tok-2" {
+		t.Errorf("calls sent %q", auth)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("token fetched %d times, want 2", n)
+	}
+}
+
+func TestOAuth2TokenError(t *testing.T) {
+	tokens, _ := newTokenServer(t, 3600)
+	api := newRecordingServer(t)
+	_, err := callWithAuth(t, api.URL, WithOAuth2ClientCredentials(tokens.URL, "client", "wrong", []string{"read", "write"}))
+	if err == nil {
+		t.Fatal("call succeeded with rejected client credentials")
+	}
+	if n := api.count(); n != 0 {
+		t.Errorf("api got %d requests without a token", n)
+	}
+}
+
+func TestOAuth2TokenFetchHonorsContext(t *testing.T) {
+	stop := make(chan struct{})
+	tokens := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		<-stop // token endpoint hangs until the test ends
+	})
+	t.Cleanup(func() { close(stop) }) // runs before tokens is closed
+	api := newRecordingServer(t)
+	opt := WithOAuth2ClientCredentials(tokens.URL, "client", "secret", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := CustomHTTPRequest(ctx, api.URL, "", "", NewOptReqParams(opt))
+	if err == nil {
+		t.Fatal("call succeeded with hanging token endpoint")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("cancelled call waited %v for token endpoint", d)
+	}
+
+	start = time.Now()
+	if _, err := callWithAuth(t, api.URL, opt, WithTimeout(50*time.Millisecond)); err == nil {
+		t.Fatal("call succeeded with hanging token endpoint")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("WithTimeout didn't apply to token fetch, call took %v", d)
+	}
+}
+
+func TestOAuth2TokenFetchUsesTransportOptions(t *testing.T) {
+	tokens := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"access_token":"tls-token","token_type":"bearer","expires_in":3600}`)
+	})
+	api := newRecordingServer(t)
+	opt := WithOAuth2ClientCredentials(tokens.URL, "client", "secret", nil)
+
+	// token endpoint has a self signed certificate, only trusted through WithTLSConfig
+	if _, err := callWithAuth(t, api.URL, opt); err == nil {
+		t.Error("token fetched without trusting the token server")
+	}
+	res, err := callWithAuth(t, api.URL, opt, WithTLSConfig(&tls.Config{RootCAs: serverCAs(tokens.Server)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	if r, _ := api.last(t); r.Header.Get("Authorization") != "Bearer tls-token" {
+		t.Errorf("api got Authorization %q", r.Header.Get("Authorization"))
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidOption is wrapped by every error returned from the E-suffixed options,
+// so callers can check for it with errors.Is
+var ErrInvalidOption = errors.New("invalid option")
+
+// OptReqParamsOptionE is same as OptReqParamsOption but is allowed to reject the value it was given
+type OptReqParamsOptionE func(*OptReqParams) error
+
+// knownHTTPMethods is the set of methods defined as constants in net/http
+var knownHTTPMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// NewOptReqParamsWithError works like NewOptReqParams but with validating options.
+// A failing option doesn't stop the constructor, remaining options are still applied and
+// all errors are returned together via errors.Join so caller sees every problem at once
+func NewOptReqParamsWithError(options ...OptReqParamsOptionE) (*OptReqParams, error) {
+	params := NewOptReqParams() // start from the same defaults
+	var errs []error
+	for _, o := range options {
+		if err := applyOptionE(params, o); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// options they are built of may keep errors for CustomHTTPRequest too
+	return params, errors.Join(append(errs, params.optErrs...)...)
+}
+
+// applyOptionE is applyOption for validating options. o is recorded as the plain options it applies,
+// like WithMethod for WithMethodE, and when it sets the fields on its own as the plain option of the
+// same name without E. rejected o leaves s as it was
+func applyOptionE(s *OptReqParams, o OptReqParamsOptionE) error {
+	var err error
+	n := len(s.appliedKinds)
+	applyOption(s, func(s *OptReqParams) {
+		transparent(s)
+		err = o(s)
+	})
+	if err == nil && len(s.appliedKinds) == n {
+		s.appliedKinds = append(s.appliedKinds, optionKind(strings.TrimSuffix(string(codeKind(o)), "E")))
+	}
+	return err
+}
+
+// WithMethodE rejects any method which is not one of the http.Method* constants
+func WithMethodE(httpMethod string) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		if err := checkMethod(httpMethod); err != nil {
+			return err
+		}
+		applyOption(s, WithMethod(httpMethod))
+		return nil
+	}
+}
+
+// WithBodyE accepts any reader, nil included which means request without body
+func WithBodyE(body io.Reader) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		applyOption(s, WithBody(body))
+		return nil
+	}
+}
+
+// WithUseInvalidTokenE has nothing to validate, it exists so E options can be used on their own
+func WithUseInvalidTokenE(useInvalidToken bool) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		applyOption(s, WithUseInvalidToken(useInvalidToken))
+		return nil
+	}
+}
+
+// WithQueryParamE rejects params having an empty key
+func WithQueryParamE(queryParam map[string]string) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		for k := range queryParam {
+			if k == "" {
+				return fmt.Errorf("%w: empty query param key", ErrInvalidOption)
+			}
+		}
+		applyOption(s, WithQueryParam(queryParam))
+		return nil
+	}
+}
+
+// WithAcceptHeaderE rejects an empty or blank accept header
+func WithAcceptHeaderE(acceptHeader string) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		if err := checkAcceptHeader(acceptHeader); err != nil {
+			return err
+		}
+		applyOption(s, WithAcceptHeader(acceptHeader))
+		return nil
+	}
+}
+
+// WithTwoValuesE validates both values the same way as WithAcceptHeaderE and WithMethodE,
+// neither is set unless both are valid
+func WithTwoValuesE(acceptHeader string, httpMethod string) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		if err := errors.Join(checkAcceptHeader(acceptHeader), checkMethod(httpMethod)); err != nil {
+			return err
+		}
+		applyOption(s, WithAcceptHeader(acceptHeader))
+		applyOption(s, WithMethod(httpMethod))
+		return nil
+	}
+}
+
+// checkMethod rejects any method which is not one of the http.Method* constants
+func checkMethod(httpMethod string) error {
+	if !knownHTTPMethods[httpMethod] {
+		return fmt.Errorf("%w: unknown http method %q", ErrInvalidOption, httpMethod)
+	}
+	return nil
+}
+
+// checkAcceptHeader rejects an empty or blank accept header
+func checkAcceptHeader(acceptHeader string) error {
+	if strings.TrimSpace(acceptHeader) == "" {
+		return fmt.Errorf("%w: empty accept header", ErrInvalidOption)
+	}
+	return nil
+}
+
+// WithBearerTokenE rejects an empty token
+func WithBearerTokenE(token string) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		if token == "" {
+			return fmt.Errorf("%w: empty bearer token", ErrInvalidOption)
+		}
+		applyOption(s, WithBearerToken(token))
+		return nil
+	}
+}
+
+// WithBaseURLE returns the parse error of base instead of keeping it for CustomHTTPRequest
+func WithBaseURLE(base string) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		if _, err := parseBaseURL(base); err != nil {
+			return err
+		}
+		applyOption(s, WithBaseURL(base))
+		return nil
+	}
+}
+
+// WithJSONBodyE returns the marshal error of v instead of keeping it for CustomHTTPRequest
+func WithJSONBodyE(v any) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("%w: json body: %v", ErrInvalidOption, err)
+		}
+		return setEncodedBody(s, "json", b, ContentTypeJSON)
+	}
+}
+
+// WithXMLBodyE returns the marshal error of v instead of keeping it for CustomHTTPRequest
+func WithXMLBodyE(v any) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		b, err := xml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("%w: xml body: %v", ErrInvalidOption, err)
+		}
+		return setEncodedBody(s, "xml", b, ContentTypeXML)
+	}
+}
+
+// WithFormBodyE returns conflict with another encoded body right away instead of keeping it for CustomHTTPRequest
+func WithFormBodyE(values url.Values) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		return addFormValues(s, values)
+	}
+}
+
+// WithGraphQLBodyE returns an empty query or marshal error of variables instead of keeping it for
+// CustomHTTPRequest
+func WithGraphQLBodyE(query string, variables map[string]any, operationName string) OptReqParamsOptionE {
+	return func(s *OptReqParams) error {
+		b, err := graphqlBody(query, variables, operationName)
+		if err != nil {
+			return err
+		}
+		if err := setEncodedBody(s, "graphql", b, ContentTypeJSON); err != nil {
+			return err
+		}
+		s.httpMethod = http.MethodPost
+		return nil
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNewOptReqParamsWithError(t *testing.T) {
+	srv := newRecordingServer(t)
+	p, err := NewOptReqParamsWithError(WithMethodE(http.MethodPut), WithAcceptHeaderE("text/plain"),
+		WithQueryParamE(map[string]string{"q": "1"}), WithBearerTokenE("tok"), WithJSONBodyE(map[string]int{"a": 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := CustomHTTPRequest(context.Background(), srv.URL, "user@example.com", "passwd", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	r, body := srv.last(t)
+	if r.Method != http.MethodPut || r.Header.Get("Accept") != "text/plain" || r.URL.Query().Get("q") != "1" ||
+		r.Header.Get("Authorization") != "Bearer tok" || body != `{"a":1}` {
+		t.Errorf("server got %s %s, accept %q, authorization %q, body %q", r.Method, r.URL, r.Header.Get("Accept"),
+			r.Header.Get("Authorization"), body)
+	}
+}
+
+func TestNewOptReqParamsWithErrorJoinsErrors(t *testing.T) {
+	p, err := NewOptReqParamsWithError(WithMethodE("FETCH"), WithAcceptHeaderE(" "), WithBearerTokenE(""),
+		WithUseInvalidTokenE(true))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("error %v, want ErrInvalidOption", err)
+	}
+	for _, want := range []string{"FETCH", "accept header", "bearer token"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q, want it to mention %q", err, want)
+		}
+	}
+	// rejected options leave the defaults, valid ones are still applied
+	if p.httpMethod != http.MethodGet || p.acceptHeader != "application/json" || !p.useInvalidToken {
+		t.Errorf("method %q, accept %q, invalid token %v", p.httpMethod, p.acceptHeader, p.useInvalidToken)
+	}
+}
+
+func TestNewOptReqParamsWithErrorReturnsOptErrs(t *testing.T) {
+	// error kept by a plain option applied inside an E option is not lost
+	withBadBase := func(s *OptReqParams) error {
+		applyOption(s, WithBaseURL("relative/"))
+		return nil
+	}
+	if _, err := NewOptReqParamsWithError(withBadBase); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("error %v, want ErrInvalidOption kept by WithBaseURL", err)
+	}
+}
+
+type xmlNote struct{ A int }
+
+func TestOptionsE(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opt     OptReqParamsOptionE
+		wantErr error
+		check   func(p *OptReqParams) bool
+	}{
+		{"method", WithMethodE(http.MethodPatch), nil, func(p *OptReqParams) bool { return p.httpMethod == http.MethodPatch }},
+		{"unknown method", WithMethodE("patch"), ErrInvalidOption, func(p *OptReqParams) bool { return p.httpMethod == http.MethodGet }},
+		{"body", WithBodyE(strings.NewReader("x")), nil, func(p *OptReqParams) bool { return p.body != nil }},
+		{"nil body", WithBodyE(nil), nil, func(p *OptReqParams) bool { return p.body == nil }},
+		{"query", WithQueryParamE(map[string]string{"a": "b"}), nil, func(p *OptReqParams) bool { return p.queryParam.Get("a") == "b" }},
+		{"empty query key", WithQueryParamE(map[string]string{"": "b"}), ErrInvalidOption, func(p *OptReqParams) bool { return p.queryParam == nil }},
+		{"accept", WithAcceptHeaderE("text/csv"), nil, func(p *OptReqParams) bool { return p.acceptHeader == "text/csv" }},
+		{"blank accept", WithAcceptHeaderE("\t"), ErrInvalidOption, func(p *OptReqParams) bool { return p.acceptHeader == "application/json" }},
+		{"two values", WithTwoValuesE("text/csv", http.MethodPost), nil, func(p *OptReqParams) bool {
+			return p.acceptHeader == "text/csv" && p.httpMethod == http.MethodPost
+		}},
+		{"two values bad method", WithTwoValuesE("text/csv", "post"), ErrInvalidOption, func(p *OptReqParams) bool {
+			return p.acceptHeader == "application/json" && p.httpMethod == http.MethodGet
+		}},
+		{"two values bad accept", WithTwoValuesE("", http.MethodPost), ErrInvalidOption, func(p *OptReqParams) bool {
+			return p.acceptHeader == "application/json" && p.httpMethod == http.MethodGet
+		}},
+		{"bearer", WithBearerTokenE("t"), nil, func(p *OptReqParams) bool { return p.bearerToken == "t" }},
+		{"empty bearer", WithBearerTokenE(""), ErrInvalidOption, func(p *OptReqParams) bool { return p.bearerToken == "" }},
+		{"base url", WithBaseURLE("https://example.com/api/"), nil, func(p *OptReqParams) bool {
+			return p.baseURL != nil && p.baseURL.Host == "example.com"
+		}},
+		{"relative base url", WithBaseURLE("api/"), ErrInvalidOption, func(p *OptReqParams) bool { return p.baseURL == nil }},
+		{"json", WithJSONBodyE([]int{1}), nil, func(p *OptReqParams) bool { return p.bodyKind == "json" }},
+		{"bad json", WithJSONBodyE(make(chan int)), ErrInvalidOption, func(p *OptReqParams) bool { return p.body == nil }},
+		{"xml", WithXMLBodyE(xmlNote{A: 1}), nil, func(p *OptReqParams) bool { return p.contentType == ContentTypeXML }},
+		{"bad xml", WithXMLBodyE(make(chan int)), ErrInvalidOption, func(p *OptReqParams) bool { return p.body == nil }},
+		{"form", WithFormBodyE(url.Values{"a": {"1"}}), nil, func(p *OptReqParams) bool { return p.formBody.Encode() == "a=1" }},
+		{"graphql", WithGraphQLBodyE("{ me }", nil, ""), nil, func(p *OptReqParams) bool { return p.httpMethod == http.MethodPost }},
+		{"empty graphql", WithGraphQLBodyE("", nil, ""), ErrInvalidOption, func(p *OptReqParams) bool { return p.httpMethod == http.MethodGet }},
+	} {
+		p, err := NewOptReqParamsWithError(tc.opt)
+		if tc.wantErr == nil && err != nil || !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.wantErr)
+		}
+		if !tc.check(p) {
+			t.Errorf("%s: params not as expected", tc.name)
+		}
+	}
+}
+
+func TestOptionsEConflictingBody(t *testing.T) {
+	p, err := NewOptReqParamsWithError(WithJSONBodyE(1), WithFormBodyE(url.Values{"a": {"1"}}))
+	if !errors.Is(err, ErrConflictingOptions) {
+		t.Errorf("error %v, want ErrConflictingOptions", err)
+	}
+	if p.bodyKind != "json" || p.formBody != nil {
+		t.Errorf("body kind %q, form %v, want json body kept", p.bodyKind, p.formBody)
+	}
+}
+
+func TestOptionsEFoundByConflictsWith(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opt  OptReqParamsOptionE
+		with OptReqParamsOption
+	}{
+		{"applies plain option", WithMethodE(http.MethodPost), WithMethod("")},
+		{"sets fields itself", WithJSONBodyE(1), WithJSONBody(nil)},
+		{"applies two", WithTwoValuesE("text/csv", http.MethodPost), WithAcceptHeader("")},
+	} {
+		p, err := NewOptReqParamsWithError(tc.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p = p.With(WithUserAgent("test").ConflictsWith(tc.with))
+		if err := p.Validate(); !errors.Is(err, ErrConflictingOptions) {
+			t.Errorf("%s: error %v, want ErrConflictingOptions", tc.name, err)
+		}
+	}
+}
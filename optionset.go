@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// optionSets is the registry behind RegisterOptionSet, guarded so lookups are safe while sets are registered
+var (
+	optionSetsMu sync.RWMutex
+	optionSets   = map[string][]OptReqParamsOption{}
+)
+
+// RegisterOptionSet stores opts under name so the configuration can be shared through the application
+// with WithOptionSet, typically from init. registering same name again replaces the set
+func RegisterOptionSet(name string, opts ...OptReqParamsOption) {
+	optionSetsMu.Lock()
+	defer optionSetsMu.Unlock()
+	optionSets[name] = slices.Clone(opts)
+}
+
+// LookupOptionSet returns options registered under name, changing returned slice doesn't change the set
+func LookupOptionSet(name string) ([]OptReqParamsOption, bool) {
+	optionSetsMu.RLock()
+	defer optionSetsMu.RUnlock()
+	opts, ok := optionSets[name]
+	return slices.Clone(opts), ok
+}
+
+// WithOptionSet applies options registered under name, in the order they were given. set is looked up
+// when option is applied, so it can be created before the set is registered. unknown name is returned
+// by CustomHTTPRequest without making any call
+func WithOptionSet(name string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		transparent(s)
+		opts, ok := LookupOptionSet(name)
+		if !ok {
+			withOptErr(fmt.Errorf("%w: option set %q not registered", ErrInvalidOption, name))(s)
+			return
+		}
+		Compose(opts...)(s)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOptionSet(t *testing.T) {
+	RegisterOptionSet("test-internal", WithTimeout(3*time.Second), WithMaxRetries(2),
+		WithHeader("X-Service", "billing"), WithUserAgent("billing/1.0"))
+
+	opts, ok := LookupOptionSet("test-internal")
+	if !ok || len(opts) != 4 {
+		t.Fatalf("LookupOptionSet = %d options, %v, want 4, true", len(opts), ok)
+	}
+	opts[0] = WithTimeout(time.Minute) // caller's copy, set stays as it is
+
+	p := NewOptReqParams(WithMethod(http.MethodPost), WithOptionSet("test-internal"), WithMaxRetries(5))
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if p.timeout != 3*time.Second || p.userAgent != "billing/1.0" || p.headers.Get("X-Service") != "billing" {
+		t.Errorf("set not applied: timeout %v, user agent %q, X-Service %q", p.timeout, p.userAgent,
+			p.headers.Get("X-Service"))
+	}
+	if p.httpMethod != http.MethodPost || p.maxRetries != 5 {
+		t.Errorf("options around the set: method %q, retries %d, want POST, 5", p.httpMethod, p.maxRetries)
+	}
+
+	if _, ok := LookupOptionSet("test-missing"); ok {
+		t.Error("LookupOptionSet found unregistered set")
+	}
+	if err := NewOptReqParams(WithOptionSet("test-missing")).Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("unregistered set: error %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestOptionSetLookedUpWhenApplied(t *testing.T) {
+	opt := WithOptionSet("test-late")
+	RegisterOptionSet("test-late", WithTimeout(time.Second))
+	if p := NewOptReqParams(opt); p.timeout != time.Second {
+		t.Errorf("timeout %v, want set registered after option was made", p.timeout)
+	}
+	RegisterOptionSet("test-late", WithTimeout(2*time.Second))
+	if p := NewOptReqParams(opt); p.timeout != 2*time.Second {
+		t.Errorf("timeout %v, want replaced set", p.timeout)
+	}
+}
+
+func TestOptionSetConcurrent(t *testing.T) {
+	RegisterOptionSet("test-shared", WithTimeout(time.Second))
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterOptionSet(fmt.Sprintf("test-concurrent-%d", i), WithMaxRetries(i))
+		}()
+		go func() {
+			defer wg.Done()
+			if p := NewOptReqParams(WithOptionSet("test-shared")); p.timeout != time.Second {
+				t.Errorf("timeout %v, want 1s", p.timeout)
+			}
+		}()
+	}
+	wg.Wait()
+}
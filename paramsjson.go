@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// paramsJSON is the JSON form of OptReqParams, keys are same as the ones LoadOptionsFromJSON knows.
+// Only plain values are kept: body, function valued fields like middleware, hooks and decoders, and
+// anything holding state like transport, limiter or caches can't be serialized and are left out.
+// Credentials are left out too so logging the JSON doesn't leak them
+type paramsJSON struct {
+	HTTPMethod      string            `json:"http_method,omitempty"`
+	AcceptHeader    string            `json:"accept_header,omitempty"`
+	ContentType     string            `json:"content_type,omitempty"`
+	UserAgent       string            `json:"user_agent,omitempty"`
+	AcceptLanguage  string            `json:"accept_language,omitempty"`
+	CacheControl    string            `json:"cache_control,omitempty"`
+	BaseURL         string            `json:"base_url,omitempty"`
+	Headers         http.Header       `json:"headers,omitempty"`
+	QueryParams     url.Values        `json:"query_params,omitempty"`
+	PathParams      map[string]string `json:"path_params,omitempty"`
+	UseInvalidToken bool              `json:"use_invalid_token,omitempty"`
+	NoAuth          bool              `json:"no_auth,omitempty"`
+	TimeoutMS       int64             `json:"timeout_ms,omitempty"`
+	MaxRetries      int               `json:"max_retries,omitempty"`
+}
+
+// MarshalJSON serializes the plain value fields of p, handy for logging exact params a call was made with.
+// Query params and headers keep all their values, unlike in LoadOptionsFromJSON they are objects of string arrays
+func (p *OptReqParams) MarshalJSON() ([]byte, error) {
+	j := paramsJSON{
+		HTTPMethod:      p.httpMethod,
+		AcceptHeader:    p.acceptHeader,
+		ContentType:     p.contentType,
+		UserAgent:       p.userAgent,
+		AcceptLanguage:  p.acceptLanguage,
+		CacheControl:    p.cacheControl,
+		Headers:         p.headers,
+		QueryParams:     p.queryParam,
+		PathParams:      p.pathParams,
+		UseInvalidToken: p.useInvalidToken,
+		NoAuth:          p.noAuth,
+		TimeoutMS:       p.timeout.Milliseconds(),
+		MaxRetries:      p.maxRetries,
+	}
+	if p.baseURL != nil {
+		j.BaseURL = p.baseURL.String()
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON restores fields written by MarshalJSON into p, fields missing in data are left as they are.
+// use it on params made by NewOptReqParams to start from the defaults
+func (p *OptReqParams) UnmarshalJSON(data []byte) error {
+	var j paramsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.BaseURL != "" {
+		u, err := parseBaseURL(j.BaseURL)
+		if err != nil {
+			return err
+		}
+		p.baseURL = u
+	}
+	if j.HTTPMethod != "" {
+		p.httpMethod = j.HTTPMethod
+	}
+	if j.AcceptHeader != "" {
+		p.acceptHeader = j.AcceptHeader
+	}
+	if j.ContentType != "" {
+		p.contentType = j.ContentType
+	}
+	if j.UserAgent != "" {
+		p.userAgent = j.UserAgent
+	}
+	if j.AcceptLanguage != "" {
+		p.acceptLanguage = j.AcceptLanguage
+	}
+	if j.CacheControl != "" {
+		p.cacheControl = j.CacheControl
+	}
+	if j.Headers != nil {
+		p.headers = j.Headers
+	}
+	if j.QueryParams != nil {
+		p.queryParam = j.QueryParams
+	}
+	if j.PathParams != nil {
+		p.pathParams = j.PathParams
+	}
+	if j.UseInvalidToken {
+		p.useInvalidToken = true
+	}
+	if j.NoAuth {
+		p.noAuth = true
+	}
+	if j.TimeoutMS != 0 {
+		p.timeout = time.Duration(j.TimeoutMS) * time.Millisecond
+	}
+	if j.MaxRetries != 0 {
+		p.maxRetries = j.MaxRetries
+	}
+	return nil
+}
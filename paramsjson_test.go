@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParamsJSONRoundTrip(t *testing.T) {
+	orig := NewOptReqParams(
+		WithMethod(http.MethodPatch),
+		WithAcceptHeader("application/xml"),
+		WithContentType(ContentTypeJSON),
+		WithUserAgent("svc/1.0"),
+		WithAcceptLanguage("en-GB"),
+		WithCacheControl("no-cache"),
+		WithBaseURL("https://api.example.com/v1/"),
+		WithHeader("X-A", "1"), WithHeader("X-A", "2"),
+		WithQueryParamSingle("q", "go"), WithQueryParamSingle("page", "2"),
+		WithPathParams(map[string]string{"id": "42"}),
+		WithUseInvalidToken(true),
+		WithNoAuth(),
+		WithTimeout(1500*time.Millisecond),
+		WithMaxRetries(3),
+	)
+	b, err := json.Marshal(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := NewOptReqParams()
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []struct {
+		name      string
+		got, want any
+	}{
+		{"method", got.httpMethod, orig.httpMethod},
+		{"accept", got.acceptHeader, orig.acceptHeader},
+		{"content type", got.contentType, orig.contentType},
+		{"user agent", got.userAgent, orig.userAgent},
+		{"accept language", got.acceptLanguage, orig.acceptLanguage},
+		{"cache control", got.cacheControl, orig.cacheControl},
+		{"base url", got.baseURL.String(), orig.baseURL.String()},
+		{"headers", got.headers, orig.headers},
+		{"query", got.queryParam, orig.queryParam},
+		{"path params", got.pathParams, orig.pathParams},
+		{"invalid token", got.useInvalidToken, orig.useInvalidToken},
+		{"no auth", got.noAuth, orig.noAuth},
+		{"timeout", got.timeout, orig.timeout},
+		{"retries", got.maxRetries, orig.maxRetries},
+	} {
+		if !reflect.DeepEqual(f.got, f.want) {
+			t.Errorf("%s: got %v, want %v", f.name, f.got, f.want)
+		}
+	}
+}
+
+func TestParamsJSONLeavesOutCredentials(t *testing.T) {
+	p := NewOptReqParams(WithBasicAuth("user", "basic-secret"), WithAPIKeyInHeader("key-secret", ""),
+		WithRequestMiddleware(func(r *http.Request) (*http.Request, error) { return r, nil }))
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"basic-secret", "key-secret"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("json has %q: %s", secret, b)
+		}
+	}
+}
+
+func TestParamsJSONUnmarshalKeepsMissingFields(t *testing.T) {
+	p := NewOptReqParams(WithUserAgent("kept/1.0"), WithTimeout(time.Second))
+	if err := json.Unmarshal([]byte(`{"http_method":"DELETE"}`), p); err != nil {
+		t.Fatal(err)
+	}
+	if p.httpMethod != http.MethodDelete || p.userAgent != "kept/1.0" || p.timeout != time.Second {
+		t.Errorf("got method %q, user agent %q, timeout %v", p.httpMethod, p.userAgent, p.timeout)
+	}
+
+	if err := json.Unmarshal([]byte(`{"base_url":"::bad"}`), p); err == nil {
+		t.Error("bad base url accepted")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// connection pool defaults for transports made by ClientPool, http.DefaultTransport keeps only 2 idle
+// connections per host which is too few for high throughput callers
+const (
+	defaultPoolMaxIdleConns        = 100
+	defaultPoolMaxIdleConnsPerHost = 10
+	defaultPoolIdleConnTimeout     = 90 * time.Second
+
+	// transports kept by a pool, when one more is needed the oldest one is dropped
+	maxPoolTransports = 64
+)
+
+// ClientPool shares transports, and so their idle connections, between calls of CustomHTTPRequest.
+// Without it calls with transport related options share transports of an internal pool, a ClientPool of
+// its own keeps them apart and tunes them for many connections. Transports are keyed by the transport
+// settings of params, clients on top of them are cheap and made per call. Safe for concurrent use
+type ClientPool struct {
+	mu         sync.Mutex
+	transports map[transportKey]http.RoundTripper
+	order      []transportKey // keys of transports, oldest first
+	tuned      bool           // transports get the pool defaults below instead of the ones of http.DefaultTransport
+}
+
+// defaultClientPool holds transports of calls without WithClientPool which have transport settings,
+// otherwise every such call would build a transport whose connections are never reused
+var defaultClientPool = &ClientPool{transports: make(map[transportKey]http.RoundTripper)}
+
+// NewClientPool returns an empty pool, transports it makes keep up to 100 idle connections,
+// 10 per host, for 90 seconds. A pool keeps up to 64 transports, one for every different combination
+// of transport settings. When more are needed, idle connections of the oldest one are closed and it
+// is dropped
+func NewClientPool() *ClientPool {
+	return &ClientPool{transports: make(map[transportKey]http.RoundTripper), tuned: true}
+}
+
+// WithClientPool makes CustomHTTPRequest take its transport from pool
+func WithClientPool(pool *ClientPool) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.clientPool = pool
+	}
+}
+
+// WithMaxIdleConnsPerHost sets how many idle connections per host the transport keeps
+func WithMaxIdleConnsPerHost(n int) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.maxIdleConnsPerHost = n
+	}
+}
+
+// transportKey fingerprints the settings buildTransport uses. TLS config, proxy and dialer are identified by
+// what the options made them of, so configs built again for every call by WithTLSInsecureSkipVerify, WithSNI
+// or WithMTLS and dialers of WithLocalAddr with same values share a transport. Configs and dialers given
+// by caller, like the transport itself, are compared by identity, so base is always a pointer
+type transportKey struct {
+	base                http.RoundTripper
+	tlsBase             *tls.Config
+	tls                 string
+	proxy               string
+	maxIdleConnsPerHost int
+	forceHTTP2          bool
+	h2c                 bool
+	disableKeepAlives   bool
+	keepAliveInterval   time.Duration
+	disableCompression  bool
+	dialer              *net.Dialer
+	dialerKey           string
+	hostOverride        string
+	dohURL              string
+	preferIPFamily      string
+	longPoll            bool
+}
+
+// transport returns pooled transport for settings of p, building it on first use
+func (cp *ClientPool) transport(p *OptReqParams) http.RoundTripper {
+	if p.transport != nil && reflect.ValueOf(p.transport).Kind() != reflect.Pointer {
+		// only pointers are keyed safely by identity, a struct of comparable type may still hold a func
+		// or map in an interface field and make the map lookup panic, so such transports aren't pooled
+		return buildTransport(p, false)
+	}
+	key := transportKey{
+		base:                p.transport,
+		tls:                 p.tlsKey,
+		tlsBase:             p.tlsBase,
+		proxy:               p.proxyKey,
+		maxIdleConnsPerHost: p.maxIdleConnsPerHost,
+		forceHTTP2:          p.forceHTTP2,
+		h2c:                 p.h2c,
+		disableKeepAlives:   p.disableKeepAlives,
+		keepAliveInterval:   p.keepAliveInterval,
+		disableCompression:  p.disableCompression,
+		dialerKey:           p.dialerKey,
+		hostOverride:        p.hostOverride,
+		dohURL:              p.dohURL,
+		preferIPFamily:      p.preferIPFamily,
+		longPoll:            p.longPoll,
+	}
+	if p.dialerKey == "" {
+		key.dialer = p.dialer
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	rt, ok := cp.transports[key]
+	if !ok {
+		rt = buildTransport(p, cp.tuned)
+		cp.add(key, rt)
+	}
+	return rt
+}
+
+// add stores rt under key, dropping the oldest transports to stay within maxPoolTransports.
+// requests still using a dropped transport finish normally, cp.mu must be held
+func (cp *ClientPool) add(key transportKey, rt http.RoundTripper) {
+	for len(cp.order) >= maxPoolTransports {
+		old := cp.order[0]
+		cp.order = cp.order[1:]
+		if c, ok := cp.transports[old].(interface{ CloseIdleConnections() }); ok {
+			c.CloseIdleConnections()
+		}
+		delete(cp.transports, old)
+	}
+	cp.transports[key] = rt
+	cp.order = append(cp.order, key)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTransportReusedAcrossCalls(t *testing.T) {
+	srv := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {})
+	for i := range 20 {
+		res, _ := mustCall(t, srv.URL, WithTLSInsecureSkipVerify(), WithSNI("example.com"))
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("call %d: status %d", i, res.StatusCode)
+		}
+	}
+	if n := srv.conns.Load(); n != 1 {
+		t.Errorf("20 calls opened %d connections, want 1", n)
+	}
+}
+
+func TestClientPoolSameSettingsShareTransport(t *testing.T) {
+	srv := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {})
+	pool := NewClientPool()
+	for range 20 {
+		mustCall(t, srv.URL, WithClientPool(pool), WithTLSInsecureSkipVerify())
+	}
+	if n := len(pool.transports); n != 1 {
+		t.Errorf("pool has %d transports, want 1", n)
+	}
+	if n := srv.conns.Load(); n != 1 {
+		t.Errorf("20 calls opened %d connections, want 1", n)
+	}
+}
+
+func TestClientPoolDropsOldestTransport(t *testing.T) {
+	pool := NewClientPool()
+	first := NewOptReqParams(WithMaxIdleConnsPerHost(1))
+	rt := pool.transport(first)
+	for i := range maxPoolTransports {
+		pool.transport(NewOptReqParams(WithMaxIdleConnsPerHost(i + 2)))
+	}
+	if n := len(pool.transports); n != maxPoolTransports {
+		t.Fatalf("pool has %d transports, want %d", n, maxPoolTransports)
+	}
+	if pool.transport(first) == rt {
+		t.Error("oldest transport was not dropped")
+	}
+}
+
+func TestClientPoolDifferentSettingsDontShare(t *testing.T) {
+	pool := NewClientPool()
+	a := pool.transport(NewOptReqParams(WithSNI("a.example.com")))
+	b := pool.transport(NewOptReqParams(WithSNI("b.example.com")))
+	if a == b {
+		t.Error("transports with different server names are shared")
+	}
+	for i := range 3 {
+		if got := pool.transport(NewOptReqParams(WithSNI("a.example.com"))); got != a {
+			t.Errorf("call %d: same server name gave another transport", i)
+		}
+	}
+}
+
+// wrappingTransport is of comparable type, but comparing two of them panics when rt is a roundTripperFunc
+type wrappingTransport struct{ rt http.RoundTripper }
+
+func (w wrappingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return w.rt.RoundTrip(r)
+}
+
+func TestClientPoolTransportOfNonComparableValue(t *testing.T) {
+	pool := NewClientPool()
+	var rt http.RoundTripper = wrappingTransport{rt: roundTripperFunc(http.DefaultTransport.RoundTrip)}
+	// second lookup with same value would compare the keys
+	for range 2 {
+		pool.transport(NewOptReqParams(WithTransport(rt), WithTLSInsecureSkipVerify()))
+	}
+	if n := len(pool.transports); n != 0 {
+		t.Errorf("pool has %d transports, want value transport not pooled", n)
+	}
+
+	base := &http.Transport{}
+	a := pool.transport(NewOptReqParams(WithTransport(base), WithTLSInsecureSkipVerify()))
+	if b := pool.transport(NewOptReqParams(WithTransport(base), WithTLSInsecureSkipVerify())); a != b {
+		t.Error("same pointer transport gave another pooled transport")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvironmentProfile holds settings which differ between environments like dev, staging and production.
+// Empty fields are not applied, so defaults or options given before WithEnvironmentProfile are kept
+type EnvironmentProfile struct {
+	BaseURL  string // see WithBaseURL
+	ProxyURL string // see WithProxy
+
+	// AuthURL is the OAuth 2.0 token url, used together with ClientID and ClientSecret,
+	// see WithOAuth2ClientCredentials
+	AuthURL      string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// WithEnvironmentProfile applies all settings of profile at once
+func WithEnvironmentProfile(profile EnvironmentProfile) OptReqParamsOption {
+	var opts []OptReqParamsOption
+	if profile.BaseURL != "" {
+		opts = append(opts, WithBaseURL(profile.BaseURL))
+	}
+	if profile.ProxyURL != "" {
+		opts = append(opts, WithProxy(profile.ProxyURL))
+	}
+	if profile.AuthURL != "" {
+		opts = append(opts, WithOAuth2ClientCredentials(profile.AuthURL, profile.ClientID, profile.ClientSecret,
+			profile.Scopes))
+	}
+	return Compose(opts...)
+}
+
+// ProfileFromEnv reads profile from environment variables named prefix followed by an underscore and
+// BASE_URL, PROXY_URL, AUTH_URL, CLIENT_ID, CLIENT_SECRET or SCOPES, like MYAPI_BASE_URL for prefix MYAPI.
+// SCOPES is a comma separated list. Variables which are not set leave their field empty
+func ProfileFromEnv(prefix string) EnvironmentProfile {
+	get := func(name string) string {
+		return os.Getenv(prefix + "_" + name)
+	}
+
+	profile := EnvironmentProfile{
+		BaseURL:      get("BASE_URL"),
+		ProxyURL:     get("PROXY_URL"),
+		AuthURL:      get("AUTH_URL"),
+		ClientID:     get("CLIENT_ID"),
+		ClientSecret: get("CLIENT_SECRET"),
+	}
+	if scopes := get("SCOPES"); scopes != "" {
+		for _, s := range strings.Split(scopes, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				profile.Scopes = append(profile.Scopes, s)
+			}
+		}
+	}
+	return profile
+}
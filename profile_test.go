@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProfileFromEnv(t *testing.T) {
+	t.Setenv("MYAPI_BASE_URL", "https://staging.example.com/v1/")
+	t.Setenv("MYAPI_PROXY_URL", "http://proxy.example.com:3128")
+	t.Setenv("MYAPI_AUTH_URL", "https://auth.example.com/token")
+	t.Setenv("MYAPI_CLIENT_ID", "client")
+	t.Setenv("MYAPI_CLIENT_SECRET", "secret")
+	t.Setenv("MYAPI_SCOPES", "read, write,,")
+	t.Setenv("OTHER_BASE_URL", "https://other.example.com")
+
+	want := EnvironmentProfile{
+		BaseURL:      "https://staging.example.com/v1/",
+		ProxyURL:     "http://proxy.example.com:3128",
+		AuthURL:      "https://auth.example.com/token",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	}
+	if got := ProfileFromEnv("MYAPI"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ProfileFromEnv() = %+v, want %+v", got, want)
+	}
+	if got := ProfileFromEnv("MISSING"); !reflect.DeepEqual(got, EnvironmentProfile{}) {
+		t.Errorf("profile of unset prefix = %+v", got)
+	}
+}
+
+func TestEnvironmentProfileApplied(t *testing.T) {
+	tokens, _ := newTokenServer(t, 3600)
+	api := newRecordingServer(t)
+	t.Setenv("MYAPI_BASE_URL", api.URL+"/v1/")
+	t.Setenv("MYAPI_AUTH_URL", tokens.URL)
+	t.Setenv("MYAPI_CLIENT_ID", "client")
+	t.Setenv("MYAPI_CLIENT_SECRET", "secret")
+	t.Setenv("MYAPI_SCOPES", "read,write")
+
+	res, err := callWithAuth(t, "items", WithEnvironmentProfile(ProfileFromEnv("MYAPI")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	req, _ := api.last(t)
+	if req.URL.Path != "/v1/items" || req.Header.Get("Authorization") != "Bearer tok-1" {
+		t.Errorf("api got %s with Authorization %q", req.URL.Path, req.Header.Get("Authorization"))
+	}
+}
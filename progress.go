@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"net/http"
+)
+
+// defaultProgressInterval is how many bytes are transferred between progress reports by default
+const defaultProgressInterval = 32 * 1024
+
+// WithUploadProgress calls fn while request body is being sent with bytes sent so far and total body size,
+// total is -1 when size is not known. fn is called every WithProgressReportInterval bytes and once at the end
+func WithUploadProgress(fn func(bytesWritten, total int64)) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.uploadProgress = fn
+	}
+}
+
+// WithDownloadProgress calls fn while response body is being read with bytes read so far and total body size,
+// total is -1 when size is not known. fn is called every WithProgressReportInterval bytes and once at the end
+func WithDownloadProgress(fn func(bytesRead, total int64)) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.downloadProgress = fn
+	}
+}
+
+// WithProgressReportInterval sets how many bytes are transferred between calls of progress callbacks,
+// default is 32 KiB
+func WithProgressReportInterval(n int64) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.progressInterval = n
+	}
+}
+
+// trackUpload makes body of req report progress if WithUploadProgress was given, retries included
+func trackUpload(req *http.Request, p *OptReqParams) {
+	if p.uploadProgress == nil || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	total := req.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+	req.Body = newProgressBody(req.Body, total, p.progressInterval, p.uploadProgress)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			rc, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newProgressBody(rc, total, p.progressInterval, p.uploadProgress), nil
+		}
+	}
+}
+
+// trackDownload makes body of res report progress if WithDownloadProgress was given
+func trackDownload(res *http.Response, p *OptReqParams) {
+	if p.downloadProgress == nil {
+		return
+	}
+	res.Body = newProgressBody(res.Body, res.ContentLength, p.progressInterval, p.downloadProgress)
+}
+
+// progressBody counts bytes read through it and reports them to fn
+type progressBody struct {
+	r io.Reader
+	io.Closer
+	fn       func(n, total int64)
+	total    int64
+	interval int64
+	n        int64
+	reported int64
+}
+
+func newProgressBody(rc io.ReadCloser, total, interval int64, fn func(n, total int64)) *progressBody {
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &progressBody{r: rc, Closer: rc, fn: fn, total: total, interval: interval}
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if b.n-b.reported >= b.interval || (err == io.EOF && b.n != b.reported) {
+		b.reported = b.n
+		b.fn(b.n, b.total)
+	}
+	return n, err
+}
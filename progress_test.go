@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// progressCall is one call of a progress callback
+type progressCall struct{ n, total int64 }
+
+func TestUploadProgress(t *testing.T) {
+	const size, interval = 100_000, 10_000
+	srv := newRecordingServer(t)
+	var calls []progressCall
+	mustCall(t, srv.URL, WithMethod(http.MethodPost), WithRawBody(bytes.Repeat([]byte("x"), size)),
+		WithProgressReportInterval(interval),
+		WithUploadProgress(func(n, total int64) { calls = append(calls, progressCall{n, total}) }))
+
+	if len(calls) == 0 || len(calls) > size/interval+1 {
+		t.Fatalf("%d progress calls for %d bytes every %d", len(calls), size, interval)
+	}
+	if last := calls[len(calls)-1]; last != (progressCall{size, size}) {
+		t.Errorf("last call %+v, want %d of %d", last, size, size)
+	}
+	for i := 1; i < len(calls)-1; i++ {
+		if calls[i].n-calls[i-1].n < interval {
+			t.Errorf("calls %+v and %+v are closer than interval", calls[i-1], calls[i])
+		}
+	}
+	if _, body := srv.last(t); len(body) != size {
+		t.Errorf("server got %d bytes", len(body))
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	const size, interval = 10_000, 1000
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		_, _ = w.Write(bytes.Repeat([]byte("y"), size))
+	})
+	var calls []progressCall
+	res, err := call(t, srv.URL, WithProgressReportInterval(interval),
+		WithDownloadProgress(func(n, total int64) { calls = append(calls, progressCall{n, total}) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	buf := make([]byte, interval)
+	read := 0
+	for {
+		n, err := io.ReadFull(res.Body, buf)
+		read += n
+		if err != nil {
+			break
+		}
+	}
+
+	var want []progressCall
+	for n := int64(interval); n <= size; n += interval {
+		want = append(want, progressCall{n, size})
+	}
+	if read != size || !slices.Equal(calls, want) {
+		t.Errorf("read %d bytes, progress calls %+v, want %+v", read, calls, want)
+	}
+}
+
+func TestDownloadProgressUnknownSize(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush() // no Content-Length, chunked
+	})
+	var calls []progressCall
+	_, body := mustCall(t, srv.URL, WithDownloadProgress(func(n, total int64) { calls = append(calls, progressCall{n, total}) }))
+	if body != "chunk" || !slices.Equal(calls, []progressCall{{5, -1}}) {
+		t.Errorf("body %q, progress calls %+v", body, calls)
+	}
+}
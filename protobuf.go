@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// WithProtobufBody marshals msg with proto.Marshal and sends it as request body with ContentTypeProtobuf.
+// Same as with WithJSONBody a marshal error is returned by CustomHTTPRequest
+func WithProtobufBody(msg proto.Message) OptReqParamsOption {
+	b, err := proto.Marshal(msg)
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: protobuf body: %v", ErrInvalidOption, err))
+			return
+		}
+		if err := setEncodedBody(s, "protobuf", b, ContentTypeProtobuf); err != nil {
+			s.optErrs = append(s.optErrs, err)
+		}
+	}
+}
+
+// NewProtobufDecoder returns a ResponseDecoder using proto.Unmarshal, target given to it must be a T
+func NewProtobufDecoder[T proto.Message]() ResponseDecoder {
+	return protobufDecoder[T]{}
+}
+
+type protobufDecoder[T proto.Message] struct{}
+
+func (protobufDecoder[T]) Decode(r io.Reader, v any) error {
+	m, ok := v.(T)
+	if !ok {
+		var want T
+		return fmt.Errorf("protobuf decoder: target is %T, not %T", v, want)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
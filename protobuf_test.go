@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtobufRoundTrip(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != ContentTypeProtobuf {
+			t.Errorf("Content-Type %q, want %q", ct, ContentTypeProtobuf)
+		}
+		w.Header().Set("Content-Type", ContentTypeProtobuf)
+		_, _ = io.Copy(w, r.Body)
+	})
+	sent, err := structpb.NewStruct(map[string]any{
+		"name":  "order",
+		"count": 3.0,
+		"paid":  true,
+		"tags":  []any{"a", "b"},
+		"lines": map[string]any{"sku": "x-1", "qty": 2.0},
+		"note":  nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewOptReqParams(WithNoAuth(), WithMethod(http.MethodPost), WithProtobufBody(sent),
+		WithResponseDecoder(NewProtobufDecoder[*structpb.Struct]()))
+	got := &structpb.Struct{}
+	if _, err := CustomHTTPRequestInto(context.Background(), srv.URL, "user@example.com", "passwd", p, got); err != nil {
+		t.Fatalf("CustomHTTPRequestInto: %v", err)
+	}
+	if !proto.Equal(got, sent) {
+		t.Errorf("decoded %v, want %v", got, sent)
+	}
+}
+
+func TestProtobufDecoderWrongTarget(t *testing.T) {
+	b, err := proto.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewProtobufDecoder[*wrapperspb.StringValue]()
+	if err := dec.Decode(strings.NewReader(string(b)), &structpb.Struct{}); err == nil {
+		t.Error("decoding into other message type succeeded")
+	}
+	got := &wrapperspb.StringValue{}
+	if err := dec.Decode(strings.NewReader(string(b)), got); err != nil || got.GetValue() != "hello" {
+		t.Errorf("decoded %q, %v, want %q", got.GetValue(), err, "hello")
+	}
+}
+
+func TestProtobufBodyMarshalError(t *testing.T) {
+	srv := newRecordingServer(t)
+	// proto3 strings must be valid UTF-8, so marshal fails
+	_, err := call(t, srv.URL, WithMethod(http.MethodPost), WithProtobufBody(wrapperspb.String("\xff")))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("error %v, want ErrInvalidOption", err)
+	}
+	if n := srv.count(); n != 0 {
+		t.Errorf("server got %d requests, want none", n)
+	}
+}
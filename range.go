@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WithRange asks for bytes from to to of the resource, both inclusive. 206 Partial Content is a normal response
+func WithRange(from, to int64) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.rangeHeader = fmt.Sprintf("bytes=%d-%d", from, to)
+	}
+}
+
+// WithRangeFrom asks for everything from byte from to the end of the resource
+func WithRangeFrom(from int64) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.rangeHeader = fmt.Sprintf("bytes=%d-", from)
+	}
+}
+
+// DownloadInChunks downloads url into w chunkSize bytes at a time, each chunk is a CustomHTTPRequest with
+// next byte range on top of p. It stops once content is exhausted, or after the first response if server
+// ignores ranges and sends whole content with 200. nil p means default params
+func DownloadInChunks(ctx context.Context, url, email, passwd string, p *OptReqParams, chunkSize int64, w io.Writer) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("%w: chunk size must be positive", ErrInvalidOption)
+	}
+	if p == nil {
+		p = NewOptReqParams()
+	}
+
+	for from := int64(0); ; {
+		res, err := CustomHTTPRequest(ctx, url, email, passwd, p.With(WithRange(from, from+chunkSize-1)))
+		if err != nil {
+			return err
+		}
+
+		switch res.StatusCode {
+		case http.StatusOK:
+			_, err = io.Copy(w, res.Body)
+			_ = res.Body.Close()
+			return err
+		case http.StatusRequestedRangeNotSatisfiable:
+			_ = res.Body.Close()
+			return nil // previous chunk ended exactly at the end
+		case http.StatusPartialContent:
+		default:
+			_ = res.Body.Close()
+			return &StatusError{StatusCode: res.StatusCode}
+		}
+
+		n, err := io.Copy(w, res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			return err
+		}
+		from += n
+
+		total, ok := contentRangeTotal(res.Header.Get("Content-Range"))
+		if n < chunkSize || (ok && from >= total) {
+			return nil
+		}
+	}
+}
+
+// contentRangeTotal returns total size from Content-Range like "bytes 0-99/1234", false when size is unknown
+func contentRangeTotal(header string) (int64, bool) {
+	_, total, ok := strings.Cut(header, "/")
+	if !ok || total == "*" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRangeServer serves content with range support, recording Range header of every request
+func newRangeServer(t *testing.T, content string) (*countingServer, func() []string) {
+	var mu sync.Mutex
+	var ranges []string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	})
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return ranges
+	}
+}
+
+func TestDownloadInChunks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		chunk   int64
+		ranges  []string
+	}{
+		{"last chunk short", "0123456789", 4, []string{"bytes=0-3", "bytes=4-7", "bytes=8-11"}},
+		{"ends on chunk boundary", "01234567", 4, []string{"bytes=0-3", "bytes=4-7"}},
+		{"one chunk", "012", 10, []string{"bytes=0-9"}},
+	} {
+		srv, ranges := newRangeServer(t, tc.content)
+		var buf bytes.Buffer
+		err := DownloadInChunks(context.Background(), srv.URL, "", "", NewOptReqParams(WithNoAuth()), tc.chunk, &buf)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if buf.String() != tc.content {
+			t.Errorf("%s: downloaded %q, want %q", tc.name, buf.String(), tc.content)
+		}
+		if got := ranges(); strings.Join(got, ",") != strings.Join(tc.ranges, ",") {
+			t.Errorf("%s: server got ranges %q, want %q", tc.name, got, tc.ranges)
+		}
+	}
+}
+
+func TestDownloadInChunksWithoutRangeSupport(t *testing.T) {
+	var calls int
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte("whole content"))
+	})
+	var buf bytes.Buffer
+	if err := DownloadInChunks(context.Background(), srv.URL, "", "", NewOptReqParams(WithNoAuth()), 4, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "whole content" || calls != 1 {
+		t.Errorf("downloaded %q in %d calls, want whole content in 1", buf.String(), calls)
+	}
+}
+
+func TestDownloadInChunksNilParams(t *testing.T) {
+	stubLogin(t, "tok")
+	srv, _ := newRangeServer(t, "0123456789")
+	var buf bytes.Buffer
+	if err := DownloadInChunks(context.Background(), srv.URL, "user@example.com", "passwd", nil, 3, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "0123456789" {
+		t.Errorf("downloaded %q", buf.String())
+	}
+}
+
+func TestDownloadInChunksErrors(t *testing.T) {
+	if err := DownloadInChunks(context.Background(), "http://example.com", "", "", nil, 0, &bytes.Buffer{}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("chunk size 0: error %v, want ErrInvalidOption", err)
+	}
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	var se *StatusError
+	err := DownloadInChunks(context.Background(), srv.URL, "", "", NewOptReqParams(WithNoAuth()), 4, &bytes.Buffer{})
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("error %v, want StatusError 404", err)
+	}
+}
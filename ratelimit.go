@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit throttles requests made with these params to rps per second, allowing bursts of burst requests.
+// zero rps means no rate limiting, burst below 1 is returned by CustomHTTPRequest without making any call.
+// Every params made with the returned option and their clones share the same limiter, so their requests
+// count against one quota
+func WithRateLimit(rps float64, burst int) OptReqParamsOption {
+	var l *rate.Limiter
+	if rps > 0 {
+		if burst < 1 {
+			return withOptErr(fmt.Errorf("%w: rate limit burst %d, must be at least 1", ErrInvalidOption, burst))
+		}
+		l = NewTokenBucketLimiter(rps, burst)
+	}
+	return func(s *OptReqParams) {
+		s.limiter = l
+	}
+}
+
+// NewTokenBucketLimiter returns a token bucket refilled with rps tokens per second holding at most burst of them,
+// every request takes one token. Give it to WithRateLimiter of many params so all their requests count against
+// one quota, like one limit per upstream api. zero rps means no limit, burst below 1 is taken as 1 as
+// a bucket without room for a token would never let any request through
+func NewTokenBucketLimiter(rps float64, burst int) *rate.Limiter {
+	if rps <= 0 {
+		return rate.NewLimiter(rate.Inf, burst)
+	}
+	return rate.NewLimiter(rate.Limit(rps), max(burst, 1))
+}
+
+// WithRateLimiter throttles requests made with these params using l, which may be shared with other params.
+// nil means no rate limiting
+func WithRateLimiter(l *rate.Limiter) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.limiter = l
+	}
+}
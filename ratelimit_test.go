@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitThroughput(t *testing.T) {
+	const (
+		rps      = 20
+		requests = 31
+		workers  = 8
+	)
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	limit := WithRateLimit(rps, 1) // one option shared by params of every request
+
+	jobs := make(chan struct{}, requests)
+	for range requests {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				if res, err := call(t, srv.URL, limit); err != nil {
+					t.Error(err)
+				} else {
+					readBody(t, res)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// first request takes the burst token, the rest wait for refills
+	got := float64(requests-1) / time.Since(start).Seconds()
+	if got < rps*0.9 || got > rps*1.1 {
+		t.Errorf("throughput %.1f requests/s, want %d within 10%%", got, rps)
+	}
+}
+
+func TestRateLimiterShared(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	l := NewTokenBucketLimiter(10, 1)
+	start := time.Now()
+	mustCall(t, srv.URL, WithRateLimiter(l))
+	mustCall(t, srv.URL, WithRateLimiter(l), WithHeader("X-Other", "params"))
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Errorf("second request with shared limiter came after %v, want about 100ms", d)
+	}
+}
+
+func TestRateLimitZeroIsUnlimited(t *testing.T) {
+	if p := NewOptReqParams(WithRateLimit(0, 1)); p.limiter != nil {
+		t.Error("zero rps set a limiter")
+	}
+}
+
+func TestTokenBucketBurstSharedByParams(t *testing.T) {
+	const (
+		rps   = 20
+		burst = 5
+		each  = 10 // requests of each params
+	)
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	l := NewTokenBucketLimiter(rps, burst)
+	params := []*OptReqParams{
+		NewOptReqParams(WithNoAuth(), WithRateLimiter(l)),
+		NewOptReqParams(WithNoAuth(), WithRateLimiter(l), WithHeader("X-Client", "b")),
+	}
+
+	start := time.Now()
+	var mu sync.Mutex
+	var times []time.Duration
+	var wg sync.WaitGroup
+	for _, p := range params {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range each {
+				res, err := CustomHTTPRequest(t.Context(), srv.URL, "", "", p)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				readBody(t, res)
+				mu.Lock()
+				times = append(times, time.Since(start))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// burst goes right away, the rest of both params wait for refills of the one bucket
+	slices.Sort(times)
+	if times[burst-1] > 40*time.Millisecond {
+		t.Errorf("burst of %d took %v, want no wait", burst, times[burst-1])
+	}
+	want := time.Duration(2*each-burst) * time.Second / rps
+	if total := times[len(times)-1]; total < want*9/10 || total > want*12/10 {
+		t.Errorf("%d requests of two params took %v, want about %v for the aggregate quota", 2*each, total, want)
+	}
+}
+
+func TestTokenBucketZeroIsUnlimited(t *testing.T) {
+	l := NewTokenBucketLimiter(0, 1)
+	for range 100 {
+		if !l.Allow() {
+			t.Fatal("zero rps limiter denied a request")
+		}
+	}
+}
+
+func TestRateLimitBurstBelowOne(t *testing.T) {
+	srv := newRecordingServer(t)
+	for _, burst := range []int{0, -1} {
+		if _, err := call(t, srv.URL, WithRateLimit(10, burst)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("burst %d: error %v, want ErrInvalidOption", burst, err)
+		}
+	}
+	if n := srv.count(); n != 0 {
+		t.Errorf("server got %d requests, want none", n)
+	}
+}
+
+func TestTokenBucketBurstBelowOne(t *testing.T) {
+	srv := newRecordingServer(t)
+	for _, burst := range []int{0, -1} {
+		// shared limiter can't fail, it gets room for one request
+		l := NewTokenBucketLimiter(10, burst)
+		if l.Burst() != 1 {
+			t.Errorf("burst %d gave limiter with burst %d, want 1", burst, l.Burst())
+		}
+		_, _ = mustCall(t, srv.URL, WithRateLimiter(l))
+	}
+	if n := srv.count(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+}
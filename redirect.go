@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// WithRedirectPolicy sets fn as http.Client.CheckRedirect. When fn returns http.ErrUseLastResponse the redirect
+// response itself is returned with its body still open, and that is not an error
+func WithRedirectPolicy(fn func(req *http.Request, via []*http.Request) error) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.checkRedirect = fn
+	}
+}
+
+// NoFollow never follows redirects, the first redirect response is returned as it is so Location can be read
+func NoFollow() OptReqParamsOption {
+	return withKind("NoFollow", WithRedirectPolicy(func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}))
+}
+
+// MaxRedirects follows at most n redirects and fails the call on the next one
+func MaxRedirects(n int) OptReqParamsOption {
+	return withKind("MaxRedirects", WithRedirectPolicy(func(_ *http.Request, via []*http.Request) error {
+		if len(via) > n {
+			return fmt.Errorf("stopped after %d redirects", n)
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newRedirectServer redirects /r/N to /r/N-1 and answers /r/0 with done
+func newRedirectServer(t *testing.T) *countingServer {
+	return newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/r/"))
+		if n == 0 {
+			_, _ = w.Write([]byte("done"))
+			return
+		}
+		http.Redirect(w, r, "/r/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+}
+
+func TestNoFollow(t *testing.T) {
+	srv := newRedirectServer(t)
+	res, _ := mustCall(t, srv.URL+"/r/2", NoFollow())
+	if res.StatusCode != http.StatusFound || res.Header.Get("Location") != "/r/1" {
+		t.Errorf("got %d to %q, want first redirect returned", res.StatusCode, res.Header.Get("Location"))
+	}
+}
+
+func TestMaxRedirects(t *testing.T) {
+	srv := newRedirectServer(t)
+	if _, body := mustCall(t, srv.URL+"/r/2", MaxRedirects(2)); body != "done" {
+		t.Errorf("2 redirects: body %q, want done", body)
+	}
+	_, err := call(t, srv.URL+"/r/3", MaxRedirects(2))
+	if err == nil || !strings.Contains(err.Error(), "stopped after 2 redirects") {
+		t.Errorf("3 redirects: error %v, want stopped after 2", err)
+	}
+}
+
+func TestWithRedirectPolicy(t *testing.T) {
+	srv := newRedirectServer(t)
+	errStop := errors.New("stop")
+	var seen []string
+	_, err := call(t, srv.URL+"/r/3", WithRedirectPolicy(func(req *http.Request, via []*http.Request) error {
+		seen = append(seen, req.URL.Path)
+		if req.URL.Path == "/r/1" {
+			return errStop
+		}
+		return nil
+	}))
+	if !errors.Is(err, errStop) {
+		t.Errorf("error %v, want error of the policy", err)
+	}
+	if strings.Join(seen, ",") != "/r/2,/r/1" {
+		t.Errorf("policy saw %q, want every redirect up to the stopped one", seen)
+	}
+}
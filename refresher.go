@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenRefresher caches token got from refresh func, shared by clones of params so they refresh only once
+type tokenRefresher struct {
+	refresh       func(ctx context.Context) (string, time.Time, error)
+	refreshBefore time.Duration
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// WithTokenRefresher gets bearer token from fn instead of MyLoginAPI. Token is cached and fn is called again
+// once less than refreshBefore is left until it expires, so requests never go out with a token about to expire.
+// Concurrent calls wait for one refresh instead of each doing their own
+func WithTokenRefresher(fn func(ctx context.Context) (token string, expiresAt time.Time, err error),
+	refreshBefore time.Duration) OptReqParamsOption {
+	r := &tokenRefresher{refresh: fn, refreshBefore: refreshBefore}
+	return func(s *OptReqParams) {
+		s.tokenRefresher = r
+	}
+}
+
+// get returns cached token, refreshing it first when it is about to expire
+func (r *tokenRefresher) get(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != "" && time.Until(r.expiresAt) >= r.refreshBefore {
+		return r.token, nil
+	}
+
+	token, expiresAt, err := r.refresh(ctx)
+	if err != nil {
+		return "", err
+	}
+	r.token, r.expiresAt = token, expiresAt
+	return token, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenRefresherRacingCallsRefreshOnce(t *testing.T) {
+	srv := newRecordingServer(t)
+	var refreshes atomic.Int32
+	refresh := WithTokenRefresher(func(ctx context.Context) (string, time.Time, error) {
+		n := refreshes.Add(1)
+		time.Sleep(50 * time.Millisecond) // keep the other goroutine waiting on this refresh
+		return fmt.Sprintf("tok-%d", n), time.Now().Add(time.Hour), nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := callWithAuth(t, srv.URL, refresh)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			readBody(t, res)
+		}()
+	}
+	wg.Wait()
+
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("refresher called %d times, want 1", n)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, req := range srv.reqs {
+		if got := req.Header.Get("Authorization"); got != "Bearer tok-1" {
+			t.Errorf("request sent %q", got)
+		}
+	}
+}
+
+func TestTokenRefresherRefreshesBeforeExpiry(t *testing.T) {
+	srv := newRecordingServer(t)
+	var refreshes atomic.Int32
+	refresh := WithTokenRefresher(func(ctx context.Context) (string, time.Time, error) {
+		n := refreshes.Add(1)
+		return fmt.Sprintf("tok-%d", n), time.Now().Add(30 * time.Second), nil // always inside refreshBefore
+	}, time.Minute)
+	for i := 1; i <= 2; i++ {
+		res, err := callWithAuth(t, srv.URL, refresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readBody(t, res)
+		if req, _ := srv.last(t); req.Header.Get("Authorization") != fmt.Sprintf("Bearer tok-%d", i) {
+			t.Errorf("call %d sent %q", i, req.Header.Get("Authorization"))
+		}
+	}
+}
+
+func TestTokenRefresherError(t *testing.T) {
+	srv := newRecordingServer(t)
+	refreshErr := errors.New("identity provider down")
+	_, err := callWithAuth(t, srv.URL, WithTokenRefresher(func(ctx context.Context) (string, time.Time, error) {
+		return "", time.Time{}, refreshErr
+	}, time.Minute))
+	if !errors.Is(err, refreshErr) || !strings.Contains(err.Error(), "refreshing token") {
+		t.Errorf("err = %v", err)
+	}
+	if n := srv.count(); n != 0 {
+		t.Errorf("server got %d requests", n)
+	}
+}
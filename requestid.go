@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header used by WithRequestID unless WithRequestIDHeader says otherwise
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// WithRequestID attaches a fresh correlation id made by generator to every request.
+// nil generator means random uuid made with crypto/rand
+func WithRequestID(generator func() string) OptReqParamsOption {
+	if generator == nil {
+		generator = newUUID
+	}
+	return func(s *OptReqParams) {
+		s.requestIDGen = generator
+	}
+}
+
+// WithRequestIDHeader renames the header used by WithRequestID
+func WithRequestIDHeader(headerName string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.requestIDHeader = headerName
+	}
+}
+
+// DefaultIdempotencyKeyHeader is the header used by WithIdempotencyKey unless WithIdempotencyKeyHeader says otherwise
+const DefaultIdempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKey sends key so server can deduplicate retried POST or PATCH requests. Empty key means a random
+// uuid made for every call. Retries of the same call always carry the same key
+func WithIdempotencyKey(key string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.useIdempotencyKey = true
+		s.idempotencyKey = key
+	}
+}
+
+// WithIdempotencyKeyHeader renames the header used by WithIdempotencyKey, like X-Idempotency-Key
+func WithIdempotencyKeyHeader(headerName string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.idempotencyKeyHeader = headerName
+	}
+}
+
+// ResponseWithRequestID pairs a response with the correlation id its request was sent with
+type ResponseWithRequestID struct {
+	*http.Response
+	RequestID string
+}
+
+// CustomHTTPRequestWithID is same as CustomHTTPRequest but also returns the request id generated for the call,
+// so callers can log the round-trip pair. Id is returned even if the call fails, Response is nil then.
+// Without WithRequestID, the id is always empty
+func CustomHTTPRequestWithID(ctx context.Context, url, email, passwd string, p *OptReqParams) (*ResponseWithRequestID, error) {
+	var id string
+	if p.requestIDGen != nil {
+		id = p.requestIDGen()
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+	}
+	res, err := CustomHTTPRequest(ctx, url, email, passwd, p)
+	return &ResponseWithRequestID{Response: res, RequestID: id}, err
+}
+
+// requestIDKey carries id made by CustomHTTPRequestWithID down to the request
+type requestIDKey struct{}
+
+// applyRequestID sets request id header if WithRequestID was given
+func applyRequestID(req *http.Request, p *OptReqParams) {
+	if p.requestIDGen == nil {
+		return
+	}
+	id, ok := req.Context().Value(requestIDKey{}).(string)
+	if !ok {
+		id = p.requestIDGen()
+	}
+	header := p.requestIDHeader
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	req.Header.Set(header, id)
+}
+
+// applyIdempotencyKey sets idempotency key header if WithIdempotencyKey was given. It is set once before
+// the first attempt, retries are clones of req and so carry the same key
+func applyIdempotencyKey(req *http.Request, p *OptReqParams) {
+	if !p.useIdempotencyKey {
+		return
+	}
+	key := p.idempotencyKey
+	if key == "" {
+		key = newUUID()
+	}
+	header := p.idempotencyKeyHeader
+	if header == "" {
+		header = DefaultIdempotencyKeyHeader
+	}
+	req.Header.Set(header, key)
+}
+
+// newUUID returns a random version 4 uuid
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) // never fails, see crypto/rand docs
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// DefaultCorrelationIDHeader is the header used by correlation id options when none is given
+const DefaultCorrelationIDHeader = "X-Correlation-ID"
+
+// WithCorrelationIDHeader forwards an existing correlation id, like one taken from incoming request, in headerName.
+// Unlike WithRequestID it doesn't make a new id. empty headerName means DefaultCorrelationIDHeader
+func WithCorrelationIDHeader(id, headerName string) OptReqParamsOption {
+	if headerName == "" {
+		headerName = DefaultCorrelationIDHeader
+	}
+	return func(s *OptReqParams) {
+		if id == "" {
+			return
+		}
+		if s.headers == nil {
+			s.headers = make(http.Header)
+		}
+		s.headers.Set(headerName, id)
+	}
+}
+
+// WithCorrelationIDFromContext is same as WithCorrelationIDHeader but takes id from value of key in ctx,
+// as set by server middleware. value must be a string or fmt.Stringer, otherwise no header is sent
+func WithCorrelationIDFromContext(ctx context.Context, key any, headerName string) OptReqParamsOption {
+	var id string
+	switch v := ctx.Value(key).(type) {
+	case string:
+		id = v
+	case fmt.Stringer:
+		id = v.String()
+	}
+	return withKind("WithCorrelationIDFromContext", WithCorrelationIDHeader(id, headerName))
+}
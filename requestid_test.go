@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	var got []string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(DefaultRequestIDHeader))
+	})
+	for range 2 {
+		mustCall(t, srv.URL, WithRequestID(nil))
+	}
+	if len(got) != 2 || got[0] == got[1] {
+		t.Fatalf("ids %q, want two different ones", got)
+	}
+	for _, id := range got {
+		if !uuidPattern.MatchString(id) {
+			t.Errorf("id %q is not a v4 uuid", id)
+		}
+	}
+}
+
+func TestRequestIDCustomGeneratorAndHeader(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Trace"); got != "fixed" {
+			t.Errorf("X-Trace = %q, want fixed", got)
+		}
+	})
+	mustCall(t, srv.URL, WithRequestID(func() string { return "fixed" }), WithRequestIDHeader("X-Trace"))
+}
+
+func TestCustomHTTPRequestWithID(t *testing.T) {
+	var sent string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		sent = r.Header.Get(DefaultRequestIDHeader)
+	})
+	p := NewOptReqParams(WithNoAuth(), WithRequestID(nil), WithMaxRetries(0))
+	res, err := CustomHTTPRequestWithID(t.Context(), srv.URL, "", "", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res.Response)
+	if res.RequestID == "" || res.RequestID != sent {
+		t.Errorf("returned id %q, sent %q", res.RequestID, sent)
+	}
+}
+
+func TestRequestIDSharedOption(t *testing.T) {
+	opt := WithRequestID(nil)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p := NewOptReqParams(opt); p.requestIDGen == nil {
+				t.Error("no generator set")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIdempotencyKeyKeptAcrossRetries(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, r.Header.Get(DefaultIdempotencyKeyHeader))
+		if len(keys)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable) // every call succeeds on its third attempt
+		}
+	})
+	for range 2 {
+		res, _ := mustCall(t, srv.URL, WithMethod(http.MethodPost), WithIdempotencyKey(""),
+			WithMaxRetries(3), WithRetryBackoff(time.Millisecond, time.Millisecond, 2))
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status %d", res.StatusCode)
+		}
+	}
+	if len(keys) != 6 {
+		t.Fatalf("server got %d attempts, want 6", len(keys))
+	}
+	for call, attempts := range [][]string{keys[:3], keys[3:]} {
+		if !uuidPattern.MatchString(attempts[0]) || attempts[1] != attempts[0] || attempts[2] != attempts[0] {
+			t.Errorf("call %d sent keys %q, want same uuid on every attempt", call, attempts)
+		}
+	}
+	if keys[0] == keys[3] {
+		t.Errorf("two calls shared key %q", keys[0])
+	}
+}
+
+func TestIdempotencyKeyFixed(t *testing.T) {
+	srv := newRecordingServer(t)
+	mustCall(t, srv.URL, WithIdempotencyKey("order-1"), WithIdempotencyKeyHeader("X-Idempotency-Key"))
+	req, _ := srv.last(t)
+	if got := req.Header.Get("X-Idempotency-Key"); got != "order-1" || req.Header.Get(DefaultIdempotencyKeyHeader) != "" {
+		t.Errorf("headers %v, want key only in X-Idempotency-Key", req.Header)
+	}
+}
+
+// correlationKey is the context key a server middleware would store correlation id under
+type correlationKey struct{}
+
+// stringerID is a correlation id type with String method
+type stringerID int
+
+func (id stringerID) String() string { return fmt.Sprintf("id-%d", int(id)) }
+
+func TestCorrelationID(t *testing.T) {
+	tests := []struct {
+		name   string
+		opt    OptReqParamsOption
+		header string
+		want   string
+	}{
+		{"given", WithCorrelationIDHeader("abc", ""), DefaultCorrelationIDHeader, "abc"},
+		{"custom header", WithCorrelationIDHeader("abc", "X-Amzn-Trace-Id"), "X-Amzn-Trace-Id", "abc"},
+		{"from context", WithCorrelationIDFromContext(
+			context.WithValue(context.Background(), correlationKey{}, "from-ctx"), correlationKey{}, ""),
+			DefaultCorrelationIDHeader, "from-ctx"},
+		{"stringer in context", WithCorrelationIDFromContext(
+			context.WithValue(context.Background(), correlationKey{}, stringerID(7)), correlationKey{}, "X-Id"),
+			"X-Id", "id-7"},
+		{"missing in context", WithCorrelationIDFromContext(context.Background(), correlationKey{}, ""),
+			DefaultCorrelationIDHeader, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Values(tt.header); len(got) > 1 || r.Header.Get(tt.header) != tt.want {
+					t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+				}
+			})
+			mustCall(t, srv.URL, tt.opt)
+		})
+	}
+}
+
+func TestCorrelationIDSharedOption(t *testing.T) {
+	opt := WithCorrelationIDHeader("abc", "")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := NewOptReqParams(opt).headers.Get(DefaultCorrelationIDHeader); got != "abc" {
+				t.Errorf("header = %q, want abc", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
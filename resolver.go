@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// DNS record types asked from DoH resolver
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+)
+
+// WithDNSOverHTTPS resolves host names with DoH resolver at resolverURL, like
+// https://cloudflare-dns.com/dns-query, instead of system DNS. JSON format of the resolver is used
+// (application/dns-json). Resolver itself is called with http.DefaultClient.
+// A bad url is returned by CustomHTTPRequest without making any call
+func WithDNSOverHTTPS(resolverURL string) OptReqParamsOption {
+	u, err := url.Parse(resolverURL)
+	if err == nil && u.Scheme != "http" && u.Scheme != "https" {
+		err = fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: dns over https url: %v", ErrInvalidOption, err))
+			return
+		}
+		s.dohURL = u.String()
+	}
+}
+
+// dohResponse is the part of DoH JSON answer we need
+type dohResponse struct {
+	Status int `json:"Status"`
+	Answer []struct {
+		Type int    `json:"type"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+// lookupDoH resolves host with DoH resolver at resolverURL. network tcp4 or tcp6 asks only for that family
+func lookupDoH(ctx context.Context, resolverURL, network, host string) ([]net.IP, error) {
+	var types []int
+	switch network {
+	case "tcp4":
+		types = []int{dnsTypeA}
+	case "tcp6":
+		types = []int{dnsTypeAAAA}
+	default:
+		types = []int{dnsTypeA, dnsTypeAAAA}
+	}
+
+	var ips []net.IP
+	var errs []error
+	for _, t := range types {
+		found, err := queryDoH(ctx, resolverURL, host, t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ips = append(ips, found...)
+	}
+	if len(ips) == 0 {
+		if len(errs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, errors.Join(errs...)
+	}
+	return ips, nil
+}
+
+// queryDoH asks resolver for records of type t of host
+func queryDoH(ctx context.Context, resolverURL, host string, t int) ([]net.IP, error) {
+	u, err := url.Parse(resolverURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("name", host)
+	q.Set("type", fmt.Sprint(t))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dns over https: unexpected status %d", res.StatusCode)
+	}
+
+	var answer dohResponse
+	if err := json.NewDecoder(res.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("dns over https: %w", err)
+	}
+	if answer.Status != 0 {
+		return nil, &net.DNSError{Err: fmt.Sprintf("rcode %d", answer.Status), Name: host, IsNotFound: answer.Status == 3}
+	}
+	var ips []net.IP
+	for _, a := range answer.Answer {
+		if a.Type != t {
+			continue // CNAME records of the chain
+		}
+		if ip := net.ParseIP(a.Data); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// resolvingDial returns dial which resolves host of addr with lookup and tries the addresses in order
+// until one connects
+func resolvingDial(dial func(ctx context.Context, network, addr string) (net.Conn, error),
+	lookup func(ctx context.Context, network, host string) ([]net.IP, error),
+) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		ips, err := lookup(ctx, network, host)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// newDoHServer answers JSON DoH queries for api.test.invalid with a CNAME chain to 127.0.0.1 and ::1,
+// every other name is NXDOMAIN
+func newDoHServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "application/dns-json" {
+			t.Errorf("Accept %q", accept)
+		}
+		name, qtype := r.URL.Query().Get("name"), r.URL.Query().Get("type")
+		w.Header().Set("Content-Type", "application/dns-json")
+		if name != "api.test.invalid" {
+			_, _ = fmt.Fprint(w, `{"Status":3}`)
+			return
+		}
+		ip := "127.0.0.1"
+		if qtype == "28" {
+			ip = "::1"
+		}
+		_, _ = fmt.Fprintf(w, `{"Status":0,"Answer":[{"type":5,"data":"edge.test.invalid."},{"type":%s,"data":"%s"}]}`,
+			qtype, ip)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLookupDoH(t *testing.T) {
+	doh := newDoHServer(t)
+	for network, want := range map[string][]string{
+		"tcp4": {"127.0.0.1"},
+		"tcp6": {"::1"},
+		"tcp":  {"127.0.0.1", "::1"},
+	} {
+		ips, err := lookupDoH(context.Background(), doh.URL, network, "api.test.invalid")
+		if err != nil {
+			t.Fatalf("%s: %v", network, err)
+		}
+		var got []string
+		for _, ip := range ips {
+			got = append(got, ip.String())
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: resolved %q, want %q", network, got, want)
+		}
+	}
+
+	_, err := lookupDoH(context.Background(), doh.URL, "tcp4", "missing.test.invalid")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("err = %v, want not found DNS error", err)
+	}
+}
+
+func TestDNSOverHTTPS(t *testing.T) {
+	doh := newDoHServer(t)
+	var host string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) { host = r.Host })
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	mustCall(t, "http://api.test.invalid:"+port, WithDNSOverHTTPS(doh.URL))
+	if host != "api.test.invalid:"+port {
+		t.Errorf("server got Host %q", host)
+	}
+	if _, err := call(t, "http://missing.test.invalid:"+port, WithDNSOverHTTPS(doh.URL)); err == nil {
+		t.Error("call to name resolver doesn't know succeeded")
+	}
+	if _, err := call(t, "http://api.test.invalid", WithDNSOverHTTPS("udp://8.8.8.8")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption", err)
+	}
+}
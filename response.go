@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrResponseTooLarge is returned while reading response body which is larger than WithMaxResponseBodySizeStrict allows
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// WithMaxResponseBodySize caps response body to n bytes, reads simply stop at the limit like with io.LimitReader.
+// zero or less means no limit
+func WithMaxResponseBodySize(n int64) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.maxResponseBodySize = n
+		s.strictResponseBodySize = false
+	}
+}
+
+// WithMaxResponseBodySizeStrict caps response body to n bytes same as WithMaxResponseBodySize, but reading
+// past the limit returns ErrResponseTooLarge so truncation can be told apart from a short body
+func WithMaxResponseBodySizeStrict(n int64) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.maxResponseBodySize = n
+		s.strictResponseBodySize = true
+	}
+}
+
+// WithResponseBodyTransformer wraps response body with fn, for bodies which must be decrypted or decoded
+// before use. It runs after status validation and before response middleware, body returned by fn has to
+// close the original one. Multiple calls compose and run in order
+func WithResponseBodyTransformer(fn func(r io.ReadCloser) io.ReadCloser) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.bodyTransformers = append(s.bodyTransformers, fn)
+	}
+}
+
+// processResponse applies response related options of p on res
+func processResponse(res *http.Response, p *OptReqParams) (*http.Response, error) {
+	if isEmptyPoll(res, p) {
+		return nil, nil
+	}
+	updateETagCache(res, p)
+	trackDownload(res, p)
+	if p.maxResponseBodySize > 0 {
+		res.Body = limitBody(res.Body, p.maxResponseBodySize, p.strictResponseBodySize)
+	}
+	if err := validateStatus(res, p); err != nil {
+		return nil, err
+	}
+	for _, fn := range p.bodyTransformers {
+		res.Body = fn(res.Body)
+	}
+	res, err := applyResponseMiddleware(res, p)
+	if err != nil || p.sseHandler == nil {
+		return res, err
+	}
+
+	// event stream is consumed here, caller gets the response only for status and headers
+	err = readSSE(res.Body, p.sseHandler)
+	_ = res.Body.Close()
+	return res, err
+}
+
+// limitBody wraps body so no more than n bytes can be read from it
+func limitBody(body io.ReadCloser, n int64, strict bool) io.ReadCloser {
+	if strict {
+		// one byte over the limit is enough to know body is too large
+		return &strictLimitedBody{r: io.LimitReader(body, n+1), Closer: body, left: n}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(body, n), body}
+}
+
+// strictLimitedBody fails with ErrResponseTooLarge once more than left bytes were read
+type strictLimitedBody struct {
+	r io.Reader
+	io.Closer
+	left int64
+}
+
+func (b *strictLimitedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if int64(n) > b.left {
+		n = int(b.left)
+		b.left = 0
+		return n, ErrResponseTooLarge
+	}
+	b.left -= int64(n)
+	return n, err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// readCloser joins reader and closer of different values
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func base64Transformer(r io.ReadCloser) io.ReadCloser {
+	return readCloser{Reader: base64.NewDecoder(base64.StdEncoding, r), Closer: r}
+}
+
+func TestResponseBodyTransformer(t *testing.T) {
+	const plaintext = "secret message"
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, base64.StdEncoding.EncodeToString([]byte(plaintext)))
+	})
+	if _, body := mustCall(t, srv.URL, WithResponseBodyTransformer(base64Transformer)); body != plaintext {
+		t.Errorf("body %q, want %q", body, plaintext)
+	}
+}
+
+func TestResponseBodyTransformersCompose(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, base64.StdEncoding.EncodeToString([]byte("quiet")))
+	})
+	upper := func(r io.ReadCloser) io.ReadCloser {
+		b, _ := io.ReadAll(r)
+		return readCloser{Reader: strings.NewReader(strings.ToUpper(string(b))), Closer: r}
+	}
+	_, body := mustCall(t, srv.URL, WithResponseBodyTransformer(base64Transformer), WithResponseBodyTransformer(upper))
+	if body != "QUIET" {
+		t.Errorf("body %q, want transformers run in order", body)
+	}
+}
+
+func TestMaxResponseBodySize(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "0123456789")
+	})
+	for _, tc := range []struct {
+		name    string
+		opt     OptReqParamsOption
+		want    string
+		wantErr error
+	}{
+		{"under limit", WithMaxResponseBodySize(20), "0123456789", nil},
+		{"truncated", WithMaxResponseBodySize(4), "0123", nil},
+		{"no limit", WithMaxResponseBodySize(0), "0123456789", nil},
+		{"strict at limit", WithMaxResponseBodySizeStrict(10), "0123456789", nil},
+		{"strict over limit", WithMaxResponseBodySizeStrict(4), "0123", ErrResponseTooLarge},
+	} {
+		res, err := call(t, srv.URL, tc.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if string(b) != tc.want || !errors.Is(err, tc.wantErr) || (tc.wantErr == nil && err != nil) {
+			t.Errorf("%s: read %q, %v, want %q, %v", tc.name, b, err, tc.want, tc.wantErr)
+		}
+	}
+}
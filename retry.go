@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is returned by CustomHTTPRequest when retries used up the time of WithRetryBudget
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// default backoff values used when WithRetryBackoff is not given
+const (
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 10 * time.Second
+	defaultRetryMultiplier     = 2.0
+)
+
+// WithMaxRetries sets how many times a failed attempt is retried, so the request is fired at most n+1 times.
+// zero (default) means no retry
+func WithMaxRetries(n int) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.maxRetries = n
+	}
+}
+
+// WithRetryBackoff sets exponential backoff between attempts. first retry waits initial, every next one
+// waits multiplier times longer than previous one but never more than max
+func WithRetryBackoff(initial, max time.Duration, multiplier float64) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.retryInitialBackoff = initial
+		s.retryMaxBackoff = max
+		s.retryMultiplier = multiplier
+	}
+}
+
+// WithRetryCondition overrides DefaultRetryCondition, fn gets result of the last attempt
+// and returns true if request should be fired again
+func WithRetryCondition(fn func(*http.Response, error) bool) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.retryCondition = fn
+	}
+}
+
+// WithRetryAfterRespect makes retries of a 429 or 503 response wait as long as its Retry-After header says,
+// in seconds or as http date, instead of the exponential backoff. DefaultRetryCondition doesn't retry 429,
+// use WithRetryCondition for that. Cap the wait with WithMaxRetryAfterWait
+func WithRetryAfterRespect() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.retryAfterRespect = true
+	}
+}
+
+// WithMaxRetryAfterWait caps how long a retry waits because of Retry-After header, zero (default) means no cap
+func WithMaxRetryAfterWait(d time.Duration) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.maxRetryAfterWait = d
+	}
+}
+
+// WithRetryBudget limits time spent on retries of one call to total, counted from when the first attempt
+// was fired. A retry which would start after that fails the call with ErrRetryBudgetExhausted instead.
+// zero (default) means no limit other than WithMaxRetries
+func WithRetryBudget(total time.Duration) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.retryBudget = total
+	}
+}
+
+// DefaultRetryCondition retries on connection errors and on 5xx status codes
+func DefaultRetryCondition(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// retryBackoff returns how long to wait before given retry, retry starts from 1
+func (p *OptReqParams) retryBackoff(retry int) time.Duration {
+	multiplier := p.retryMultiplier
+	if multiplier == 0 {
+		multiplier = defaultRetryMultiplier // params not made by NewOptReqParams
+	}
+	d := float64(p.retryInitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if d > float64(p.retryMaxBackoff) {
+		return p.retryMaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// retryWait returns how long to wait before given retry after previous attempt gave res
+func (p *OptReqParams) retryWait(retry int, res *http.Response) time.Duration {
+	if !p.retryAfterRespect || res == nil ||
+		(res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable) {
+		return p.retryBackoff(retry)
+	}
+	d, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return p.retryBackoff(retry)
+	}
+	if p.maxRetryAfterWait > 0 && d > p.maxRetryAfterWait {
+		return p.maxRetryAfterWait
+	}
+	return d
+}
+
+// parseRetryAfter parses Retry-After value, either seconds or http date which is relative to now
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0), true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// doWithRetry fires req with client and retries it as configured in p, then runs WithOnResponse hooks
+// and records metrics with the final outcome. Elapsed time is measured from when rate limiter let the first
+// attempt through until the last attempt returned
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request, p *OptReqParams) (*http.Response, error) {
+	if err := waitRateLimit(ctx, p); err != nil {
+		runOnResponse(p, nil, err, 0)
+		recordMetrics(p, req, nil, err, 0)
+		return nil, err
+	}
+
+	start := time.Now()
+	res, err := retryLoop(ctx, client, req, p)
+	elapsed := time.Since(start)
+	runOnResponse(p, res, err, elapsed)
+	recordMetrics(p, req, res, err, elapsed)
+	return res, err
+}
+
+// retryLoop does the attempts for doWithRetry. req body is rewound for every retry using req.GetBody
+// and every retry waits for rate limiter, same as the first attempt
+func retryLoop(ctx context.Context, client *http.Client, req *http.Request, p *OptReqParams) (*http.Response, error) {
+	shouldRetry := p.retryCondition
+	if shouldRetry == nil {
+		shouldRetry = DefaultRetryCondition
+	}
+
+	budgetEnd := time.Now().Add(p.retryBudget)
+	attemptReq := req
+	for retry := 0; ; retry++ {
+		res, err := doAttempt(ctx, client, attemptReq, p)
+		if retry >= p.maxRetries || ctx.Err() != nil || !shouldRetry(res, err) {
+			return res, err
+		}
+		prevRes, prevErr := res, err
+
+		// drain the response we are discarding so its connection can be reused
+		if res != nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+
+		wait := p.retryWait(retry+1, res)
+		if p.retryBudget > 0 {
+			wait = min(wait, time.Until(budgetEnd)) // don't sleep past the budget just to fail
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return nil, err
+		}
+		if p.retryBudget > 0 && !time.Now().Before(budgetEnd) {
+			return nil, fmt.Errorf("%w: %v spent on %d attempts, last one: %v", ErrRetryBudgetExhausted,
+				p.retryBudget, retry+1, attemptOutcome(prevRes, prevErr))
+		}
+		if err := waitRateLimit(ctx, p); err != nil {
+			return nil, err
+		}
+
+		attemptReq = req.Clone(ctx)
+		if req.GetBody != nil {
+			if attemptReq.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		runOnRetry(p, retry+1, attemptReq, prevRes, prevErr)
+	}
+}
+
+// attemptOutcome describes result of an attempt for error messages
+func attemptOutcome(res *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return res.Status
+}
+
+// waitRateLimit blocks until rate limiter of p allows one more request, if there is one
+func waitRateLimit(ctx context.Context, p *OptReqParams) error {
+	if p.limiter == nil {
+		return nil
+	}
+	return p.limiter.Wait(ctx)
+}
+
+// sleepCtx waits for d or until ctx is done, whichever comes first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRetryAfterServer answers first request with status and Retry-After value, later ones with 200.
+// It returns func giving times of requests it got
+func newRetryAfterServer(t *testing.T, status int, retryAfter string) (*countingServer, func() []time.Time) {
+	var mu sync.Mutex
+	var at []time.Time
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if at = append(at, time.Now()); len(at) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(status)
+		}
+	})
+	return srv, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return at
+	}
+}
+
+func TestRetryAfterRespected(t *testing.T) {
+	srv, at := newRetryAfterServer(t, http.StatusServiceUnavailable, "2")
+	res, _ := mustCall(t, srv.URL, WithMaxRetries(1), WithRetryAfterRespect(),
+		WithRetryBackoff(time.Millisecond, time.Millisecond, 2))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	times := at()
+	if len(times) != 2 {
+		t.Fatalf("server got %d requests", len(times))
+	}
+	if wait := times[1].Sub(times[0]); wait < 1900*time.Millisecond || wait > 2200*time.Millisecond {
+		t.Errorf("retry came after %v, want about 2s", wait)
+	}
+}
+
+func TestRetryAfterTooManyRequests(t *testing.T) {
+	srv, at := newRetryAfterServer(t, http.StatusTooManyRequests, "1")
+	retry429 := WithRetryCondition(func(res *http.Response, err error) bool {
+		return err != nil || res.StatusCode == http.StatusTooManyRequests
+	})
+	mustCall(t, srv.URL, WithMaxRetries(1), WithRetryAfterRespect(), WithMaxRetryAfterWait(100*time.Millisecond), retry429)
+	times := at()
+	if len(times) != 2 {
+		t.Fatalf("server got %d requests", len(times))
+	}
+	if wait := times[1].Sub(times[0]); wait > 500*time.Millisecond {
+		t.Errorf("retry came after %v, want Retry-After capped to 100ms", wait)
+	}
+}
+
+func TestRetryAfterIgnoredWithoutOption(t *testing.T) {
+	srv, at := newRetryAfterServer(t, http.StatusServiceUnavailable, "2")
+	mustCall(t, srv.URL, WithMaxRetries(1), WithRetryBackoff(time.Millisecond, time.Millisecond, 2))
+	if times := at(); len(times) != 2 || times[1].Sub(times[0]) > 500*time.Millisecond {
+		t.Errorf("retry waited for Retry-After without WithRetryAfterRespect")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		v    string
+		want time.Duration
+		ok   bool
+	}{
+		{"2", 2 * time.Second, true},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"Fri, 01 Mar 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Fri, 01 Mar 2024 11:00:00 GMT", 0, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseRetryAfter(tt.v, now); got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.v, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	const total = 500 * time.Millisecond
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	start := time.Now()
+	_, err := call(t, srv.URL, WithMaxRetries(1000), WithRetryBudget(total),
+		WithRetryBackoff(30*time.Millisecond, 30*time.Millisecond, 1))
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("err = %v, want ErrRetryBudgetExhausted", err)
+	}
+	if elapsed < total*9/10 || elapsed > total*11/10 {
+		t.Errorf("call took %v, want %v within 10%%", elapsed, total)
+	}
+}
+
+func TestRetryBudgetNotReachedReturnsLastResponse(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	res, _ := mustCall(t, srv.URL, WithMaxRetries(2), WithRetryBudget(time.Minute),
+		WithRetryBackoff(time.Millisecond, time.Millisecond, 1))
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want last 503 once retries ran out within budget", res.StatusCode)
+	}
+}
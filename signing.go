@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// DefaultSignatureHeader is used by WithHMACSignature when no header name is given
+const DefaultSignatureHeader = "X-Signature"
+
+// WithHMACSignature signs request body with secret, webhook style like GitHub or Stripe do, and sends base64
+// encoded signature in headerName. nil hash means sha256.New. Body is buffered to compute the signature
+func WithHMACSignature(secret []byte, headerName string, hash func() hash.Hash) OptReqParamsOption {
+	if hash == nil {
+		hash = sha256.New
+	}
+	if headerName == "" {
+		headerName = DefaultSignatureHeader
+	}
+	return func(s *OptReqParams) {
+		s.hmacSecret = secret
+		s.hmacHeader = headerName
+		s.hmacHash = hash
+	}
+}
+
+// signBody sets HMAC signature header on req if WithHMACSignature was given. Body without it signs as empty
+func signBody(req *http.Request, p *OptReqParams) error {
+	if p.hmacSecret == nil {
+		return nil
+	}
+	body, err := bufferedBody(req)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(p.hmacHash, p.hmacSecret)
+	mac.Write(body)
+	req.Header.Set(p.hmacHeader, base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// bufferedBody returns a copy of req body, req must have been built from a replayable body
+func bufferedBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// validSignature tells in constant time whether signature is base64 HMAC of body, the way a receiver checks it
+func validSignature(secret, body []byte, signature string, newHash func() hash.Hash) bool {
+	got, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	secret, body := []byte("secret"), []byte("payload")
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !validSignature(secret, body, sig, sha256.New) {
+		t.Error("reference signature rejected")
+	}
+	if validSignature([]byte("other"), body, sig, sha256.New) || validSignature(secret, body, "!!", sha256.New) {
+		t.Error("bad signature accepted")
+	}
+}
+
+func TestHMACSignature(t *testing.T) {
+	secret := []byte("s3cret")
+	tests := []struct {
+		name    string
+		header  string
+		hash    func() hash.Hash
+		want    string
+		newHash func() hash.Hash
+	}{
+		{"defaults", "", nil, DefaultSignatureHeader, sha256.New},
+		{"custom", "X-Hub-Signature", sha512.New, "X-Hub-Signature", sha512.New},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if !validSignature(secret, body, r.Header.Get(tt.want), tt.newHash) {
+					t.Errorf("signature %q in %s doesn't match body %q", r.Header.Get(tt.want), tt.want, body)
+				}
+			})
+			// reader without Seek, body still has to be buffered for signing
+			body := io.MultiReader(strings.NewReader(`{"event":`), strings.NewReader(`"push"}`))
+			mustCall(t, srv.URL, WithMethod(http.MethodPost), WithBody(body),
+				WithHMACSignature(secret, tt.header, tt.hash))
+		})
+	}
+}
+
+func TestHMACSignatureSharedOption(t *testing.T) {
+	opt := WithHMACSignature([]byte("secret"), "", nil)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p := NewOptReqParams(opt); p.hmacHeader != DefaultSignatureHeader || p.hmacHash == nil {
+				t.Errorf("got header %q, hash set %v", p.hmacHeader, p.hmacHash != nil)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// credentials, date and expected signatures from the AWS SigV4 test suite
+const (
+	sigV4TestAccessKey = "AKIDEXAMPLE"
+	sigV4TestSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+var sigV4TestDate = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSigV4TestSuite(t *testing.T) {
+	tests := []struct {
+		name, method, signature string
+	}{
+		{"get-vanilla", http.MethodGet, "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", http.MethodPost, "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+	p := NewOptReqParams(WithAWSSigV4(sigV4TestAccessKey, sigV4TestSecretKey, "us-east-1", "service"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "https://example.amazonaws.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.sigV4.sign(req, sigV4TestDate); err != nil {
+				t.Fatal(err)
+			}
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization\n got %s\nwant %s", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date %q", got)
+			}
+		})
+	}
+}
+
+var sigV4AuthPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/\d{8}/eu-west-1/execute-api/aws4_request, ` +
+	`SignedHeaders=([a-z0-9;-]+), Signature=[0-9a-f]{64}$`)
+
+func TestSigV4Request(t *testing.T) {
+	srv := newRecordingServer(t)
+	res, err := callWithAuth(t, srv.URL+"/items?b=2&a=1", WithMethod(http.MethodPost), WithRawBody([]byte(`{"n":1}`)),
+		WithAWSSigV4(sigV4TestAccessKey, sigV4TestSecretKey, "eu-west-1", "execute-api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, res)
+	req, body := srv.last(t)
+	m := sigV4AuthPattern.FindStringSubmatch(req.Header.Get("Authorization"))
+	if m == nil {
+		t.Fatalf("Authorization %q", req.Header.Get("Authorization"))
+	}
+	for _, h := range []string{"content-type", "host", "x-amz-date"} {
+		if !strings.Contains(";"+m[1]+";", ";"+h+";") {
+			t.Errorf("signed headers %q miss %s", m[1], h)
+		}
+	}
+	if body != `{"n":1}` {
+		t.Errorf("server got body %q after signing buffered it", body)
+	}
+}
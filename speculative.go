@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// SpeculativeHandle gives result of a request started by WithSpeculativeExecution
+type SpeculativeHandle struct {
+	ctx    context.Context
+	url    string
+	params *OptReqParams
+
+	once sync.Once
+	done chan struct{}
+	res  *http.Response
+	err  error
+}
+
+// WithSpeculativeExecution prefetches speculativeURL with speculativeParams in the background as soon as the
+// request these options are used for starts, for when that request is always followed by the speculative one.
+// Prefetch uses ctx, not context of the request, and same credentials. It is started only once even when
+// params are used for many requests. Take its result from the returned handle
+func WithSpeculativeExecution(ctx context.Context, speculativeURL string,
+	speculativeParams *OptReqParams) (OptReqParamsOption, *SpeculativeHandle) {
+	if speculativeParams == nil {
+		speculativeParams = NewOptReqParams()
+	}
+	h := &SpeculativeHandle{ctx: ctx, url: speculativeURL, params: speculativeParams, done: make(chan struct{})}
+	return func(s *OptReqParams) {
+		s.speculative = h
+	}, h
+}
+
+// GetSpeculativeResult waits for the prefetch to finish and returns what CustomHTTPRequest gave for it.
+// Caller has to close response body. It blocks until ctx is done when the prefetch was never started
+func (h *SpeculativeHandle) GetSpeculativeResult(ctx context.Context) (*http.Response, error) {
+	select {
+	case <-h.done:
+		return h.res, h.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// start fires the prefetch in background unless it was started before
+func (h *SpeculativeHandle) start(email, passwd string) {
+	h.once.Do(func() {
+		go func() {
+			defer close(h.done)
+			h.res, h.err = CustomHTTPRequest(h.ctx, h.url, email, passwd, h.params)
+		}()
+	})
+}
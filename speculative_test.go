@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSpeculativeExecution(t *testing.T) {
+	var prefetches atomic.Int32
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/next" {
+			prefetches.Add(1)
+			_, _ = io.WriteString(w, "next page")
+			return
+		}
+		time.Sleep(100 * time.Millisecond) // prefetch finishes while this one is still served
+	})
+	opt, h := WithSpeculativeExecution(context.Background(), srv.URL+"/next", NewOptReqParams(WithNoAuth()))
+	mustCall(t, srv.URL+"/page", opt)
+	mustCall(t, srv.URL+"/page", opt)
+
+	select {
+	case <-h.done:
+	default:
+		t.Fatal("prefetch not finished when main request returned")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := h.GetSpeculativeResult(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := readBody(t, res); body != "next page" {
+		t.Errorf("prefetched body %q", body)
+	}
+	if n := prefetches.Load(); n != 1 {
+		t.Errorf("prefetched %d times, want once for both requests", n)
+	}
+}
+
+func TestSpeculativeNotStarted(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {})
+	opt, h := WithSpeculativeExecution(context.Background(), srv.URL+"/next", nil)
+	if _, err := call(t, srv.URL, opt, WithDryRun()); !errors.Is(err, ErrDryRun) {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := h.GetSpeculativeResult(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded for prefetch never started", err)
+	}
+	if n := srv.conns.Load(); n != 0 {
+		t.Errorf("dry run opened %d connections", n)
+	}
+}
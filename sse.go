@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is one event of a text/event-stream response. Event is empty for the default message type
+// and ID stays the one of last event which had it, same as browsers do
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// WithSSEHandler makes CustomHTTPRequest read response as a server-sent events stream and call fn for every
+// event, Accept is set to text/event-stream. CustomHTTPRequest returns once server closes the stream or fn
+// returns an error, which is then returned by CustomHTTPRequest. Response body is already closed by then
+func WithSSEHandler(fn func(event SSEEvent) error) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.sseHandler = fn
+		s.acceptHeader = "text/event-stream"
+	}
+}
+
+// readSSE parses body as event stream and passes every complete event to fn
+func readSSE(body io.Reader, fn func(SSEEvent) error) error {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024) // data lines can be long
+
+	var ev SSEEvent
+	var data []string
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			// blank line ends the event, one without data is not dispatched
+			if data != nil {
+				ev.Data = strings.Join(data, "\n")
+				if err := fn(ev); err != nil {
+					return err
+				}
+			}
+			ev = SSEEvent{ID: ev.ID}
+			data = nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment, often used as keep-alive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.ID = value
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				ev.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSSEServer streams chunks as separate flushed writes of a text/event-stream response
+func newSSEServer(t *testing.T, chunks ...string) *countingServer {
+	return newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "text/event-stream" {
+			t.Errorf("Accept %q", accept)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			_, _ = io.WriteString(w, c)
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
+
+func TestSSEEventsInOrder(t *testing.T) {
+	srv := newSSEServer(t,
+		": keep-alive\n\n",
+		"id: 1\ndata: first\n\n",
+		"event: update\ndata: line one\ndata: line two\n\n",
+		"id: 3\nretry: 1500\ndata:no space\n",
+		"\n",
+		"event: ignored without data\n\n",
+		"data: last\n\n",
+	)
+	var got []SSEEvent
+	res, err := call(t, srv.URL, WithSSEHandler(func(ev SSEEvent) error {
+		got = append(got, ev)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status %d", res.StatusCode)
+	}
+	want := []SSEEvent{
+		{ID: "1", Data: "first"},
+		{ID: "1", Event: "update", Data: "line one\nline two"},
+		{ID: "3", Data: "no space", Retry: 1500 * time.Millisecond},
+		{ID: "3", Data: "last"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestSSEHandlerErrorStopsStream(t *testing.T) {
+	srv := newSSEServer(t, "data: 1\n\n", "data: 2\n\n", "data: 3\n\n")
+	stop := errors.New("enough")
+	var n int
+	_, err := call(t, srv.URL, WithSSEHandler(func(ev SSEEvent) error {
+		if n++; ev.Data == "2" {
+			return stop
+		}
+		return nil
+	}))
+	if !errors.Is(err, stop) || n != 2 {
+		t.Errorf("err = %v after %d events, want handler error after 2", err, n)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// StatusError is returned by the built-in status validators
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected http status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// WithStatusValidator makes CustomHTTPRequest check response status with fn, when fn returns an error
+// response body is closed and the error is returned instead of the response.
+// 304 answering a WithIfNoneMatch or WithETagCache request is never passed to fn
+func WithStatusValidator(fn func(statusCode int) error) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.statusValidator = fn
+	}
+}
+
+// WithAutoDecodeErrorBody decodes response body into target with dec when status validator fails,
+// so error details sent by server are not lost
+func WithAutoDecodeErrorBody(dec ResponseDecoder, target any) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.errorBodyDecoder = dec
+		s.errorBodyTarget = target
+	}
+}
+
+// ValidateSuccess accepts only 2xx status codes
+func ValidateSuccess() func(statusCode int) error {
+	return func(statusCode int) error {
+		if statusCode < 200 || statusCode > 299 {
+			return &StatusError{StatusCode: statusCode}
+		}
+		return nil
+	}
+}
+
+// Validate2xxOr3xx accepts 2xx and 3xx status codes
+func Validate2xxOr3xx() func(statusCode int) error {
+	return func(statusCode int) error {
+		if statusCode < 200 || statusCode > 399 {
+			return &StatusError{StatusCode: statusCode}
+		}
+		return nil
+	}
+}
+
+// ValidateNot5xx accepts everything but server errors
+func ValidateNot5xx() func(statusCode int) error {
+	return func(statusCode int) error {
+		if statusCode >= 500 {
+			return &StatusError{StatusCode: statusCode}
+		}
+		return nil
+	}
+}
+
+// validateStatus runs status validator of p on res, closing the body if it fails
+func validateStatus(res *http.Response, p *OptReqParams) error {
+	if p.statusValidator == nil || isNotModified(res) {
+		return nil
+	}
+	err := p.statusValidator(res.StatusCode)
+	if err == nil {
+		return nil
+	}
+
+	if p.errorBodyDecoder != nil && p.errorBodyTarget != nil {
+		if decErr := p.errorBodyDecoder.Decode(res.Body, p.errorBodyTarget); decErr != nil {
+			err = fmt.Errorf("%w (decoding error body: %v)", err, decErr)
+		}
+	}
+	_ = res.Body.Close()
+	return err
+}
+
+// isNotModified tells whether res is a 304 answer to a conditional request
+func isNotModified(res *http.Response) bool {
+	return res.StatusCode == http.StatusNotModified && res.Request != nil && res.Request.Header.Get("If-None-Match") != ""
+}
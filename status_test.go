@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// newStatusServer answers with status code given in code query param
+func newStatusServer(t *testing.T) *countingServer {
+	return newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		w.WriteHeader(code)
+	})
+}
+
+func TestStatusValidators(t *testing.T) {
+	srv := newStatusServer(t)
+	for _, tc := range []struct {
+		name      string
+		validator func(int) error
+		ok        []int
+		fail      []int
+	}{
+		{"success", ValidateSuccess(), []int{200, 204, 299}, []int{304, 404, 500}},
+		{"2xx or 3xx", Validate2xxOr3xx(), []int{200, 304}, []int{404, 503}},
+		{"not 5xx", ValidateNot5xx(), []int{200, 304, 404}, []int{500, 503}},
+	} {
+		for _, code := range tc.ok {
+			res, err := call(t, srv.URL+"?code="+strconv.Itoa(code), WithStatusValidator(tc.validator))
+			if err != nil || res.StatusCode != code {
+				t.Errorf("%s: status %d gave error %v", tc.name, code, err)
+				continue
+			}
+			readBody(t, res)
+		}
+		for _, code := range tc.fail {
+			res, err := call(t, srv.URL+"?code="+strconv.Itoa(code), WithStatusValidator(tc.validator))
+			var se *StatusError
+			if res != nil || !errors.As(err, &se) || se.StatusCode != code {
+				t.Errorf("%s: status %d gave %v, %v, want StatusError", tc.name, code, res, err)
+			}
+		}
+	}
+}
+
+func TestStatusValidatorCustom(t *testing.T) {
+	srv := newStatusServer(t)
+	errTeapot := errors.New("teapot")
+	_, err := call(t, srv.URL+"?code=418", WithStatusValidator(func(code int) error {
+		if code == http.StatusTeapot {
+			return errTeapot
+		}
+		return nil
+	}))
+	if !errors.Is(err, errTeapot) {
+		t.Errorf("error %v, want error of the validator", err)
+	}
+}
+
+func TestStatusValidatorSkipsNotModified(t *testing.T) {
+	srv := newStatusServer(t)
+	res, _ := mustCall(t, srv.URL+"?code=304", WithStatusValidator(ValidateSuccess()), WithIfNoneMatch(`"v1"`))
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("status %d, want 304 passed through", res.StatusCode)
+	}
+}
+
+func TestAutoDecodeErrorBody(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message":"name is required"}`))
+	})
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	_, err := call(t, srv.URL, WithStatusValidator(ValidateSuccess()), WithAutoDecodeErrorBody(NewJSONDecoder(), &apiErr))
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadRequest {
+		t.Errorf("error %v, want StatusError 400", err)
+	}
+	if apiErr.Message != "name is required" {
+		t.Errorf("decoded %+v, want message of the server", apiErr)
+	}
+
+	// a body which can't be decoded is reported along with the status error
+	_, err = call(t, srv.URL, WithStatusValidator(ValidateSuccess()), WithAutoDecodeErrorBody(NewXMLDecoder(), &apiErr))
+	if !errors.As(err, &se) {
+		t.Errorf("error %v, want StatusError", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of spans made by WithOTelTracer
+const tracerName = "github.com/ashishsnigam/go-function-options"
+
+// WithOTelTracer makes CustomHTTPRequest create a client span with tracer from tp for every call.
+// span context is sent with the request using otel.GetTextMapPropagator, or as W3C traceparent and
+// tracestate headers when application didn't set one. method, host, status code and error are recorded
+// on the span
+func WithOTelTracer(tp trace.TracerProvider) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.tracerProvider = tp
+	}
+}
+
+// startSpan starts client span for the call if p has a tracer provider, returned func ends it with the outcome.
+// without tracer provider ctx is returned as it is and end func does nothing
+func startSpan(ctx context.Context, p *OptReqParams) (context.Context, func(*http.Response, error)) {
+	if p.tracerProvider == nil {
+		return ctx, func(*http.Response, error) {}
+	}
+
+	ctx, span := p.tracerProvider.Tracer(tracerName).Start(ctx, p.httpMethod,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("http.request.method", p.httpMethod)))
+	return ctx, func(res *http.Response, err error) {
+		if res != nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+			if res.StatusCode >= http.StatusBadRequest {
+				span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+			}
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// injectTrace adds span context of req to its headers and records the host it goes to, only when tracing is on
+func injectTrace(req *http.Request, p *OptReqParams) {
+	if p.tracerProvider == nil {
+		return
+	}
+	trace.SpanFromContext(req.Context()).SetAttributes(
+		attribute.String("server.address", req.URL.Hostname()),
+		attribute.String("url.full", req.URL.Redacted()))
+	propagator := otel.GetTextMapPropagator()
+	if len(propagator.Fields()) == 0 {
+		propagator = propagation.TraceContext{} // global one does nothing until otel.SetTextMapPropagator
+	}
+	propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// baggageHeader is the W3C Baggage header
+const baggageHeader = "Baggage"
+
+// WithBaggageHeader sends baggage as W3C Baggage header, like "userId=alice,tenant=acme;prio=1".
+// value is normalized to canonical serialization, a malformed one is returned by CustomHTTPRequest
+// without making any call
+func WithBaggageHeader(value string) OptReqParamsOption {
+	b, err := baggage.Parse(value)
+	if err != nil {
+		return withOptErr(fmt.Errorf("%w: baggage: %v", ErrInvalidOption, err))
+	}
+	return withKind("WithBaggageHeader", withBaggage(b))
+}
+
+// WithBaggageFromContext sends OpenTelemetry baggage found in ctx as W3C Baggage header,
+// nothing is sent if ctx has no baggage
+func WithBaggageFromContext(ctx context.Context) OptReqParamsOption {
+	return withKind("WithBaggageFromContext", withBaggage(baggage.FromContext(ctx)))
+}
+
+// withBaggage sets Baggage header to serialization of b, replacing one set before
+func withBaggage(b baggage.Baggage) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		if b.Len() == 0 {
+			return
+		}
+		if s.headers == nil {
+			s.headers = make(http.Header)
+		}
+		s.headers.Set(baggageHeader, b.String())
+	}
+}
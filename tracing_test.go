@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newRecordingTracer returns tracer provider keeping ended spans in the returned recorder
+func newRecordingTracer(t *testing.T) (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	t.Cleanup(func() { _ = tp.Shutdown(t.Context()) })
+	return tp, rec
+}
+
+// spanAttr returns value of attribute key of span, empty value if it has none
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestOTelTracer(t *testing.T) {
+	tp, rec := newRecordingTracer(t)
+	var traceparent string
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusTeapot)
+	})
+	mustCall(t, srv.URL, WithOTelTracer(tp))
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("span kind %v, want client", span.SpanKind())
+	}
+	if got := spanAttr(span, "http.request.method").AsString(); got != http.MethodGet {
+		t.Errorf("method attribute %q", got)
+	}
+	if got := spanAttr(span, "http.response.status_code").AsInt64(); got != http.StatusTeapot {
+		t.Errorf("status code attribute %d", got)
+	}
+	if got := spanAttr(span, "server.address").AsString(); got != "127.0.0.1" {
+		t.Errorf("server address attribute %q", got)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("span status %v, want error for 418", span.Status().Code)
+	}
+
+	// no propagator set by the test, default one still sends span context
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("traceparent %q, want %q", traceparent, want)
+	}
+}
+
+func TestOTelTracerRecordsError(t *testing.T) {
+	tp, rec := newRecordingTracer(t)
+	_, err := call(t, "http://127.0.0.1:1", WithOTelTracer(tp))
+	if err == nil {
+		t.Fatal("call to closed port succeeded")
+	}
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error || len(spans[0].Events()) == 0 {
+		t.Errorf("error not recorded on span, status %v", spans[0].Status())
+	}
+}
+
+func TestNoTracerNoHeaders(t *testing.T) {
+	srv := newCountingServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("traceparent") != "" {
+			t.Error("traceparent sent without tracer")
+		}
+	})
+	mustCall(t, srv.URL)
+}
+
+// baggageMembers returns list members of a Baggage header sorted, their order is not significant
+func baggageMembers(header string) []string {
+	if header == "" {
+		return nil
+	}
+	members := strings.Split(header, ",")
+	slices.Sort(members)
+	return members
+}
+
+func TestBaggageHeader(t *testing.T) {
+	srv := newRecordingServer(t)
+	for _, tc := range []struct{ value, want string }{
+		{"userId=alice", "userId=alice"},
+		{" userId = alice , tenant=acme;prio=1 ", "tenant=acme;prio=1,userId=alice"},
+		{"name=alice%20smith;flag", "name=alice%20smith;flag"},
+	} {
+		_, _ = mustCall(t, srv.URL, WithBaggageHeader(tc.value))
+		r, _ := srv.last(t)
+		if got := baggageMembers(r.Header.Get("Baggage")); !reflect.DeepEqual(got, baggageMembers(tc.want)) {
+			t.Errorf("Baggage(%q) sent as %q, want %q", tc.value, r.Header.Get("Baggage"), tc.want)
+		}
+	}
+
+	if _, err := call(t, srv.URL, WithBaggageHeader("no value")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("malformed baggage: error %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestBaggageFromContext(t *testing.T) {
+	srv := newRecordingServer(t)
+	prop, err := baggage.NewKeyValueProperty("prio", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := baggage.NewMemberRaw("userId", "alice smith", prop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := baggage.NewMemberRaw("tenant", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := baggage.New(user, tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = mustCall(t, srv.URL, WithBaggageFromContext(baggage.ContextWithBaggage(t.Context(), b)))
+	r, _ := srv.last(t)
+	want := []string{"tenant=acme", "userId=alice%20smith;prio=1"}
+	if got := baggageMembers(r.Header.Get("Baggage")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Baggage header %q, want members %q", r.Header.Get("Baggage"), want)
+	}
+
+	_, _ = mustCall(t, srv.URL, WithBaggageFromContext(t.Context()))
+	if r, _ := srv.last(t); r.Header.Values("Baggage") != nil {
+		t.Errorf("context without baggage sent Baggage %q", r.Header.Values("Baggage"))
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// WithTransport makes CustomHTTPRequest send requests through t instead of http.DefaultTransport,
+// useful for custom transports and for stubbing the network out
+func WithTransport(t http.RoundTripper) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.transport = t
+	}
+}
+
+// WithTLSConfig sets TLS settings used for handshake like private CA or client certificates.
+// Like everywhere else in Go, cfg must not be modified once it was used
+// If WithTransport is also given, it must be an *http.Transport, the TLS config replaces its own one.
+// Certificates added by WithMTLS before are kept
+func WithTLSConfig(cfg *tls.Config) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.tlsConfig, s.tlsBase, s.tlsKey = cfg, cfg, ""
+		if len(s.clientCerts) > 0 {
+			if s.tlsConfig = cfg.Clone(); s.tlsConfig == nil {
+				s.tlsConfig = &tls.Config{}
+			}
+			s.tlsConfig.Certificates = append(s.tlsConfig.Certificates, s.clientCerts...)
+			for _, c := range s.clientCerts {
+				s.tlsKey += certKey(c)
+			}
+		}
+	}
+}
+
+// WithMTLS presents client certificate made of PEM encoded certPEM and keyPEM in TLS handshake, for mutual TLS.
+// It is added to certificates of the TLS config, whether WithTLSConfig comes before or after it.
+// A bad pair is returned by CustomHTTPRequest without making any call
+func WithMTLS(certPEM, keyPEM []byte) OptReqParamsOption {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	key := certKey(cert)
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: client certificate: %v", ErrInvalidOption, err))
+			return
+		}
+		cfg := &tls.Config{}
+		if s.tlsConfig != nil {
+			cfg = s.tlsConfig.Clone() // keep whatever was set before
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+		s.tlsConfig, s.tlsKey = cfg, s.tlsKey+key
+		s.clientCerts = append(s.clientCerts, cert)
+	}
+}
+
+// certKey identifies client certificate c in tlsKey by hash of its leaf
+func certKey(c tls.Certificate) string {
+	if len(c.Certificate) == 0 {
+		return " cert"
+	}
+	return fmt.Sprintf(" cert %x", sha256.Sum256(c.Certificate[0]))
+}
+
+// WithTLSInsecureSkipVerify turns off server certificate verification, meant only for test environments
+func WithTLSInsecureSkipVerify() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		cfg := &tls.Config{}
+		if s.tlsConfig != nil {
+			cfg = s.tlsConfig.Clone() // keep whatever was set before
+		}
+		cfg.InsecureSkipVerify = true
+		s.tlsConfig, s.tlsKey = cfg, s.tlsKey+" insecure"
+	}
+}
+
+// WithSNI sets server name sent in TLS handshake and checked against server certificate, instead of
+// host of the url. Useful with WithHostOverride when the target serves more than one name
+func WithSNI(serverName string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		cfg := &tls.Config{}
+		if s.tlsConfig != nil {
+			cfg = s.tlsConfig.Clone() // keep whatever was set before
+		}
+		cfg.ServerName = serverName
+		s.tlsConfig, s.tlsKey = cfg, s.tlsKey+" sni "+serverName
+	}
+}
+
+// WithProxy routes requests through proxy at proxyURL, scheme must be http, https or socks5.
+// A bad url is returned by CustomHTTPRequest without making any call
+func WithProxy(proxyURL string) OptReqParamsOption {
+	u, err := url.Parse(proxyURL)
+	if err == nil && u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" {
+		err = fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, fmt.Errorf("%w: proxy url: %v", ErrInvalidOption, err))
+			return
+		}
+		s.proxy = http.ProxyURL(u)
+		s.proxyKey = u.String()
+	}
+}
+
+// WithProxyFromEnvironment takes proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func WithProxyFromEnvironment() OptReqParamsOption {
+	return func(s *OptReqParams) {
+		s.proxy = http.ProxyFromEnvironment
+		s.proxyKey = "environment"
+	}
+}
+
+// newHTTPClient builds the client for CustomHTTPRequest out of the transport related fields of p.
+// zero values of these fields mean same as zero value http.Client. Transports built for transport
+// settings come from defaultClientPool unless WithClientPool is given, so calls with same settings
+// share one transport and reuse its connections
+func newHTTPClient(p *OptReqParams) *http.Client {
+	rt := p.transport
+	switch {
+	case p.clientPool != nil:
+		rt = p.clientPool.transport(p)
+	case p.needsOwnTransport():
+		rt = defaultClientPool.transport(p)
+	}
+
+	return &http.Client{
+		Transport:     wrapTransport(rt, p),
+		Timeout:       p.effectiveTimeout(),
+		Jar:           p.cookieJar,
+		CheckRedirect: p.checkRedirect,
+	}
+}
+
+// buildTransport returns user given transport as it is, unless p has settings which can only be applied
+// on an *http.Transport. tuned transport always is a new *http.Transport tuned for connection reuse
+func buildTransport(p *OptReqParams, tuned bool) http.RoundTripper {
+	if !tuned && !p.needsOwnTransport() {
+		return p.transport
+	}
+	if p.h2c {
+		return newH2CTransport(p)
+	}
+
+	t := cloneTransport(p.transport)
+	if tuned {
+		t.MaxIdleConns = defaultPoolMaxIdleConns
+		t.MaxIdleConnsPerHost = defaultPoolMaxIdleConnsPerHost
+		t.IdleConnTimeout = defaultPoolIdleConnTimeout
+	}
+	if p.maxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = p.maxIdleConnsPerHost
+	}
+	if p.tlsConfig != nil {
+		t.TLSClientConfig = p.tlsConfig.Clone()
+	}
+	if p.proxy != nil {
+		t.Proxy = p.proxy
+	}
+	if p.disableKeepAlives {
+		t.DisableKeepAlives = true
+	}
+	if p.disableCompression {
+		t.DisableCompression = true
+	}
+	if p.longPoll {
+		t.IdleConnTimeout = 0 // transport is shared by polls, keep connection for the next one however long it waits
+	}
+	if dial := dialContext(p); dial != nil {
+		t.DialContext = dial
+	}
+	if p.forceHTTP2 {
+		configureHTTP2(t)
+	}
+	return t
+}
+
+// wrapTransport adds round trippers of options which work on top of any transport
+func wrapTransport(rt http.RoundTripper, p *OptReqParams) http.RoundTripper {
+	if p.useDigestAuth {
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		rt = &digestTransport{next: rt, username: p.digestUser, password: p.digestPasswd}
+	}
+	return rt
+}
+
+// needsOwnTransport tells whether p has settings which can only be applied on an *http.Transport
+func (p *OptReqParams) needsOwnTransport() bool {
+	return p.tlsConfig != nil || p.proxy != nil || p.maxIdleConnsPerHost > 0 || p.forceHTTP2 || p.h2c ||
+		p.disableKeepAlives || p.disableCompression || p.longPoll || p.needsOwnDialer()
+}
+
+// cloneTransport copies rt if it is an *http.Transport, otherwise it copies http.DefaultTransport.
+// copy is needed so user given transport is never modified. Validate makes sure rt is nil or an *http.Transport
+func cloneTransport(rt http.RoundTripper) *http.Transport {
+	if t, ok := rt.(*http.Transport); ok {
+		return t.Clone()
+	}
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
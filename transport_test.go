@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// roundTripperFunc adapts a func to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestTransportStubsNetwork(t *testing.T) {
+	var got *http.Request
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		return &http.Response{StatusCode: http.StatusTeapot, Body: io.NopCloser(strings.NewReader("stub")),
+			Header: make(http.Header), Request: r}, nil
+	})
+	res, body := mustCall(t, "http://example.invalid/x", WithTransport(rt))
+	if res.StatusCode != http.StatusTeapot || body != "stub" {
+		t.Errorf("got %d %q, want response of stub transport", res.StatusCode, body)
+	}
+	if got == nil || got.URL.Path != "/x" {
+		t.Errorf("transport got request %v", got)
+	}
+}
+
+func TestTransportOfTLSTestServer(t *testing.T) {
+	srv := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})
+	if _, err := call(t, srv.URL); err == nil {
+		t.Fatal("call to test server with default transport trusted its certificate")
+	}
+	if _, body := mustCall(t, srv.URL, WithTransport(srv.Client().Transport)); body != "ok" {
+		t.Errorf("body %q", body)
+	}
+}
+
+func TestTLSConfigRejectsOtherTransport(t *testing.T) {
+	srv := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {})
+	rt := roundTripperFunc(http.DefaultTransport.RoundTrip)
+	_, err := call(t, srv.URL, WithTransport(rt), WithTLSInsecureSkipVerify())
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("err = %v, want ErrInvalidOption instead of dropping the transport", err)
+	}
+	if n := srv.conns.Load(); n != 0 {
+		t.Errorf("server got %d connections", n)
+	}
+}
+
+func TestTLSConfigPrivateCA(t *testing.T) {
+	srv := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {})
+	res, _ := mustCall(t, srv.URL, WithTLSConfig(&tls.Config{RootCAs: serverCAs(srv.Server)}))
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status %d", res.StatusCode)
+	}
+	if _, err := call(t, srv.URL, WithTLSConfig(&tls.Config{RootCAs: x509.NewCertPool()})); err == nil {
+		t.Error("server certificate accepted without its CA")
+	}
+}
+
+func TestTLSConfigClientCertificate(t *testing.T) {
+	certPEM, keyPEM, cert := newClientCert(t)
+	srv := newMTLSServer(t, cert)
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &tls.Config{RootCAs: serverCAs(srv), Certificates: []tls.Certificate{pair}}
+	if _, body := mustCall(t, srv.URL, WithTLSConfig(cfg)); body != "test client" {
+		t.Errorf("server saw client %q", body)
+	}
+	if _, err := call(t, srv.URL, WithTLSConfig(&tls.Config{RootCAs: serverCAs(srv)})); err == nil {
+		t.Error("server requiring client certificate accepted call without one")
+	}
+}
+
+func TestTLSInsecureSkipVerify(t *testing.T) {
+	srv := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {})
+	if res, _ := mustCall(t, srv.URL, WithTLSInsecureSkipVerify()); res.StatusCode != http.StatusOK {
+		t.Errorf("status %d", res.StatusCode)
+	}
+}
+
+// newTestProxy starts a plain HTTP proxy on a net.Listener which answers every request itself with
+// the url it was asked for, so a test can tell the request went through it
+func newTestProxy(t *testing.T) (proxyURL string, requests *atomic.Int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	requests = new(atomic.Int32)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					req, err := http.ReadRequest(br)
+					if err != nil {
+						return
+					}
+					requests.Add(1)
+					body := "proxied " + req.URL.String()
+					res := &http.Response{StatusCode: http.StatusOK, ProtoMajor: 1, ProtoMinor: 1,
+						ContentLength: int64(len(body)), Body: io.NopCloser(strings.NewReader(body))}
+					if err := res.Write(conn); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String(), requests
+}
+
+func TestProxy(t *testing.T) {
+	proxyURL, requests := newTestProxy(t)
+	_, body := mustCall(t, "http://example.invalid/path?q=1", WithProxy(proxyURL))
+	if body != "proxied http://example.invalid/path?q=1" {
+		t.Errorf("body %q, want answer of proxy", body)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("proxy got %d requests", n)
+	}
+}
+
+func TestProxyBadURL(t *testing.T) {
+	for _, proxyURL := range []string{"ftp://127.0.0.1:21", "http://[::1"} {
+		if _, err := call(t, "http://example.invalid", WithProxy(proxyURL)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: err = %v, want ErrInvalidOption", proxyURL, err)
+		}
+	}
+}
+
+func TestMTLS(t *testing.T) {
+	certPEM, keyPEM, cert := newClientCert(t)
+	srv := newMTLSServer(t, cert)
+	for name, opts := range map[string][]OptReqParamsOption{
+		"alone":            {WithMTLS(certPEM, keyPEM), WithTLSConfig(&tls.Config{RootCAs: serverCAs(srv)})},
+		"tls config first": {WithTLSConfig(&tls.Config{RootCAs: serverCAs(srv)}), WithMTLS(certPEM, keyPEM)},
+		"insecure":         {WithMTLS(certPEM, keyPEM), WithTLSInsecureSkipVerify()},
+	} {
+		res, body := mustCall(t, srv.URL, opts...)
+		if res.TLS == nil || body != "test client" {
+			t.Errorf("%s: server saw client %q", name, body)
+		}
+	}
+}
+
+func TestMTLSBadPair(t *testing.T) {
+	certPEM, _, _ := newClientCert(t)
+	_, otherKey, _ := newClientCert(t)
+	for name, opt := range map[string]OptReqParamsOption{
+		"garbage":        WithMTLS([]byte("not pem"), []byte("not pem")),
+		"mismatched key": WithMTLS(certPEM, otherKey),
+	} {
+		if _, err := call(t, "https://example.invalid", opt); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: err = %v, want ErrInvalidOption", name, err)
+		}
+	}
+}
+
+func TestSNIWithHostOverride(t *testing.T) {
+	var serverName, host string
+	srv := newCountingServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+		serverName, host = r.TLS.ServerName, r.Host
+	})
+	addr := srv.Listener.Addr().String()
+	roots := WithTLSConfig(&tls.Config{RootCAs: serverCAs(srv.Server)})
+
+	// test server certificate is for example.com, not for the host dialed through the override
+	mustCall(t, "https://api.internal.invalid/x", roots, WithHostOverride(addr), WithSNI("example.com"))
+	if serverName != "example.com" || host != "api.internal.invalid" {
+		t.Errorf("server got SNI %q and Host %q", serverName, host)
+	}
+	if _, err := call(t, "https://api.internal.invalid/x", roots, WithHostOverride(addr)); err == nil {
+		t.Error("handshake passed without SNI matching the certificate")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// statusResumeIncomplete is what resumable upload servers answer for a chunk which didn't finish the upload,
+// same code as 308 Permanent Redirect but without Location so it is never followed
+const statusResumeIncomplete = http.StatusPermanentRedirect
+
+// ChunkedUpload uploads totalSize bytes of r to url with PUT requests of chunkSize bytes, each with its
+// Content-Range, using resumable upload protocol like Google Cloud Storage does. It first asks the server how
+// much it already has with an empty PUT of Content-Range: bytes */total, so an interrupted upload resumes
+// where it stopped. Server answers 308 with Range of bytes it has for unfinished uploads and 200 or 201 once
+// done. A failed chunk is sent again, from what the server confirmed, up to WithMaxRetries times
+func ChunkedUpload(ctx context.Context, url, email, passwd string, r io.ReadSeeker, totalSize int64,
+	chunkSize int64, p *OptReqParams) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("%w: chunk size must be positive", ErrInvalidOption)
+	}
+	if p == nil {
+		p = NewOptReqParams()
+	}
+	// chunks do their own retry and need to see 308 whatever status validator p has
+	base := p.With(WithMethod(http.MethodPut), WithMaxRetries(0), WithStatusValidator(nil),
+		WithContentType(ContentTypeOctetStream))
+
+	offset, done, err := uploadState(ctx, url, email, passwd, base, totalSize)
+	if err != nil || done {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	for failures := 0; offset < totalSize; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(r, buf[:min(chunkSize, totalSize-offset)])
+		if err != nil {
+			return err
+		}
+
+		chunk := base.With(WithRawBody(buf[:n]),
+			WithHeader("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(n)-1, totalSize)))
+		next, done, err := uploadResult(CustomHTTPRequest(ctx, url, email, passwd, chunk))
+		if done {
+			return nil
+		}
+		if err == nil && next > offset {
+			offset, failures = next, 0
+			continue
+		}
+		if err == nil {
+			err = fmt.Errorf("upload made no progress at offset %d", offset)
+		}
+
+		// ask server what it got before sending the chunk again
+		if failures++; failures > p.maxRetries || ctx.Err() != nil {
+			return err
+		}
+		if offset, done, err = uploadState(ctx, url, email, passwd, base, totalSize); err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadState asks server how many bytes of the upload it has
+func uploadState(ctx context.Context, url, email, passwd string, base *OptReqParams,
+	totalSize int64) (int64, bool, error) {
+	query := base.With(WithRawBody(nil), WithHeader("Content-Range", fmt.Sprintf("bytes */%d", totalSize)))
+	return uploadResult(CustomHTTPRequest(ctx, url, email, passwd, query))
+}
+
+// uploadResult reads outcome of an upload request, it returns offset server has up to or true when upload is done
+func uploadResult(res *http.Response, err error) (int64, bool, error) {
+	if err != nil {
+		return 0, false, err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, nil
+	case statusResumeIncomplete:
+		return receivedUpTo(res.Header.Get("Range")), false, nil
+	default:
+		return 0, false, &StatusError{StatusCode: res.StatusCode}
+	}
+}
+
+// receivedUpTo returns offset following received bytes in Range like "bytes=0-1023", 0 when there is no such header
+func receivedUpTo(header string) int64 {
+	_, end, ok := strings.Cut(header, "-")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n + 1
+}
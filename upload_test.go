@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// uploadServer is a resumable upload endpoint keeping bytes it got, fail decides per chunk Content-Range
+// how many bytes of the chunk are kept before it answers 503
+type uploadServer struct {
+	mu     sync.Mutex
+	data   []byte
+	ranges []string
+	fail   func(contentRange string) (keep int, failed bool)
+}
+
+func (u *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	_, _ = body.ReadFrom(r.Body)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	cr := r.Header.Get("Content-Range")
+	u.ranges = append(u.ranges, cr)
+
+	var start, end, total int
+	if _, err := fmt.Sscanf(cr, "bytes */%d", &total); err == nil {
+		u.answer(w, total)
+		return
+	}
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &total); err != nil || start != len(u.data) ||
+		end-start+1 != body.Len() {
+		http.Error(w, "bad Content-Range "+cr, http.StatusBadRequest)
+		return
+	}
+	if u.fail != nil {
+		if keep, failed := u.fail(cr); failed {
+			u.data = append(u.data, body.Bytes()[:keep]...)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	u.data = append(u.data, body.Bytes()...)
+	u.answer(w, total)
+}
+
+// answer tells client how much of total server has
+func (u *uploadServer) answer(w http.ResponseWriter, total int) {
+	switch {
+	case len(u.data) == total:
+		w.WriteHeader(http.StatusCreated)
+	case len(u.data) > 0:
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(u.data)-1))
+		w.WriteHeader(statusResumeIncomplete)
+	default:
+		w.WriteHeader(statusResumeIncomplete)
+	}
+}
+
+func TestChunkedUpload(t *testing.T) {
+	const content = "0123456789"
+	for _, tc := range []struct {
+		name    string
+		already string
+		fail    func(string) (int, bool)
+		want    []string
+	}{
+		{
+			name: "fresh",
+			want: []string{"bytes */10", "bytes 0-3/10", "bytes 4-7/10", "bytes 8-9/10"},
+		},
+		{
+			name:    "resumes interrupted upload",
+			already: "012345",
+			want:    []string{"bytes */10", "bytes 6-9/10"},
+		},
+		{
+			name: "mid upload failure",
+			fail: func() func(string) (int, bool) {
+				failed := false
+				return func(cr string) (int, bool) {
+					// second chunk breaks after 1 byte, only once
+					if cr == "bytes 4-7/10" && !failed {
+						failed = true
+						return 1, true
+					}
+					return 0, false
+				}
+			}(),
+			want: []string{"bytes */10", "bytes 0-3/10", "bytes 4-7/10", "bytes */10", "bytes 5-8/10", "bytes 9-9/10"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			up := &uploadServer{data: []byte(tc.already), fail: tc.fail}
+			srv := newCountingServer(t, false, up.ServeHTTP)
+			err := ChunkedUpload(context.Background(), srv.URL, "", "", strings.NewReader(content), int64(len(content)),
+				4, NewOptReqParams(WithNoAuth(), WithMaxRetries(2)))
+			if err != nil {
+				t.Fatalf("ChunkedUpload: %v", err)
+			}
+			if string(up.data) != content {
+				t.Errorf("server has %q, want %q", up.data, content)
+			}
+			if !reflect.DeepEqual(up.ranges, tc.want) {
+				t.Errorf("Content-Range sequence %q, want %q", up.ranges, tc.want)
+			}
+		})
+	}
+}
+
+func TestChunkedUploadRetryLimit(t *testing.T) {
+	up := &uploadServer{fail: func(string) (int, bool) { return 0, true }}
+	srv := newCountingServer(t, false, up.ServeHTTP)
+	err := ChunkedUpload(context.Background(), srv.URL, "", "", strings.NewReader("0123456789"), 10, 4,
+		NewOptReqParams(WithNoAuth(), WithMaxRetries(2)))
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("error %v, want 503 StatusError", err)
+	}
+	chunks := 0
+	for _, cr := range up.ranges {
+		if cr == "bytes 0-3/10" {
+			chunks++
+		}
+	}
+	if chunks != 3 {
+		t.Errorf("first chunk sent %d times, want 3 with 2 retries", chunks)
+	}
+}
+
+func TestChunkedUploadAlreadyDone(t *testing.T) {
+	up := &uploadServer{data: []byte("0123456789")}
+	srv := newCountingServer(t, false, up.ServeHTTP)
+	if err := ChunkedUpload(context.Background(), srv.URL, "", "", strings.NewReader("0123456789"), 10, 4,
+		NewOptReqParams(WithNoAuth())); err != nil {
+		t.Fatalf("ChunkedUpload: %v", err)
+	}
+	if want := []string{"bytes */10"}; !reflect.DeepEqual(up.ranges, want) {
+		t.Errorf("Content-Range sequence %q, want %q", up.ranges, want)
+	}
+}
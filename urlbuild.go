@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// ErrMissingPathParam is returned when url has a {placeholder} which has no value in WithPathParams
+var ErrMissingPathParam = errors.New("missing path param")
+
+// pathParamPattern matches placeholders like {id} in url templates
+var pathParamPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// WithPathParams expands {key} placeholders in url passed to CustomHTTPRequest like /users/{id}.
+// values are path escaped so slashes and other special characters are safe. Multiple calls merge
+func WithPathParams(params map[string]string) OptReqParamsOption {
+	return func(s *OptReqParams) {
+		if s.pathParams == nil {
+			s.pathParams = make(map[string]string, len(params))
+		}
+		for k, v := range params {
+			s.pathParams[k] = v
+		}
+	}
+}
+
+// WithBaseURL makes url passed to CustomHTTPRequest relative to base, resolved with url.ResolveReference.
+// An absolute url still wins over base. base is parsed right away and if that fails CustomHTTPRequest
+// returns the error without making any call, use WithBaseURLE to get it at construction time instead.
+// Like with any reference resolving, base should end with a slash to keep its last path segment
+func WithBaseURL(base string) OptReqParamsOption {
+	u, err := parseBaseURL(base)
+	return func(s *OptReqParams) {
+		if err != nil {
+			s.optErrs = append(s.optErrs, err)
+			return
+		}
+		s.baseURL = u
+	}
+}
+
+// parseBaseURL accepts only absolute urls since a relative base resolves to nothing useful
+func parseBaseURL(base string) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("%w: base url: %v", ErrInvalidOption, err)
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%w: base url %q is not absolute", ErrInvalidOption, base)
+	}
+	return u, nil
+}
+
+// resolveURL builds final url out of rawURL and url related fields of p
+func resolveURL(rawURL string, p *OptReqParams) (string, error) {
+	expanded, err := expandPathParams(rawURL, p)
+	if err != nil {
+		return "", err
+	}
+	if p.baseURL == nil {
+		return withOrigin(expanded, p.origin)
+	}
+
+	ref, err := url.Parse(expanded)
+	if err != nil {
+		return "", err
+	}
+	if ref.IsAbs() {
+		return withOrigin(expanded, p.origin)
+	}
+	return withOrigin(p.baseURL.ResolveReference(ref).String(), p.origin)
+}
+
+// expandPathParams replaces {key} placeholders in rawURL with values from WithPathParams
+func expandPathParams(rawURL string, p *OptReqParams) (string, error) {
+	if p.pathParams == nil {
+		return rawURL, nil
+	}
+
+	var missing []error
+	expanded := pathParamPattern.ReplaceAllStringFunc(rawURL, func(m string) string {
+		key := m[1 : len(m)-1]
+		v, ok := p.pathParams[key]
+		if !ok {
+			missing = append(missing, fmt.Errorf("%w: %q", ErrMissingPathParam, key))
+			return m
+		}
+		return url.PathEscape(v)
+	})
+	if missing != nil {
+		return "", errors.Join(missing...)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPathParams(t *testing.T) {
+	srv := newRecordingServer(t)
+	params := map[string]string{"id": "42", "ver": "2", "name": "a b/c"}
+	tests := []struct {
+		name, path, want string
+	}{
+		{"whole segment", "/users/{id}", "/users/42"},
+		{"start of segment", "/{id}-orders", "/42-orders"},
+		{"middle of segment", "/api/v{ver}beta/x", "/api/v2beta/x"},
+		{"end of segment", "/files/report-{id}", "/files/report-42"},
+		{"escaped value", "/files/{name}", "/files/a%20b%2Fc"},
+		{"several", "/{ver}/{id}/{id}", "/2/42/42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mustCall(t, srv.URL+tt.path, WithPathParams(params))
+			req, _ := srv.last(t)
+			if got := req.URL.EscapedPath(); got != tt.want {
+				t.Errorf("path %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathParamsMissing(t *testing.T) {
+	srv := newRecordingServer(t)
+	_, err := call(t, srv.URL+"/users/{id}/{other}", WithPathParams(map[string]string{"id": "1"}))
+	if !errors.Is(err, ErrMissingPathParam) {
+		t.Errorf("err = %v, want ErrMissingPathParam", err)
+	}
+	if n := srv.count(); n != 0 {
+		t.Errorf("server got %d requests", n)
+	}
+}
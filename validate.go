@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrMisconfiguration is wrapped by warnings of ValidateStrict, these combinations work but are likely a mistake
+var ErrMisconfiguration = errors.New("possible misconfiguration")
+
+// Validate checks p for options which can't work together or are incomplete and returns all problems found
+// joined together, including bad values given to options. CustomHTTPRequest calls it before doing anything
+func (p *OptReqParams) Validate() error {
+	errs := append([]error(nil), p.optErrs...)
+	conflict := func(a, b string) {
+		errs = append(errs, fmt.Errorf("%w: %s and %s", ErrConflictingOptions, a, b))
+	}
+
+	// explicit auth modes against each other and against invalid token and no auth
+	modes := p.authModes()
+	for i, mode := range modes {
+		for _, other := range modes[:i] {
+			conflict(other, mode)
+		}
+		if p.useInvalidToken {
+			conflict(mode, "WithUseInvalidToken")
+		}
+		if p.noAuth {
+			conflict(mode, "WithNoAuth")
+		}
+	}
+	if p.noAuth && p.useInvalidToken {
+		conflict("WithNoAuth", "WithUseInvalidToken")
+	}
+	if p.useBearerToken && p.bearerToken == "" {
+		errs = append(errs, fmt.Errorf("%w: empty bearer token", ErrInvalidOption))
+	}
+
+	if p.transport != nil && p.h2c {
+		conflict("WithTransport", "WithH2C")
+	} else if _, ok := p.transport.(*http.Transport); p.transport != nil && !ok && p.needsOwnTransport() {
+		errs = append(errs, fmt.Errorf("%w: WithTransport given %T, transport options need an *http.Transport",
+			ErrInvalidOption, p.transport))
+	}
+	if p.h2c && p.tlsConfig != nil {
+		conflict("WithH2C", "TLS config")
+	}
+	if p.h2c && p.forceHTTP2 {
+		conflict("WithH2C", "WithHTTP2")
+	}
+	if p.h2c && p.proxy != nil {
+		conflict("WithH2C", "proxy")
+	}
+	if p.maxRetries < 0 {
+		errs = append(errs, fmt.Errorf("%w: negative max retries %d", ErrInvalidOption, p.maxRetries))
+	}
+	if p.retryMultiplier != 0 && p.retryMultiplier < 1 { // zero is unset, default is used
+		errs = append(errs, fmt.Errorf("%w: retry multiplier %v less than 1", ErrInvalidOption, p.retryMultiplier))
+	}
+	if p.retryInitialBackoff > p.retryMaxBackoff {
+		errs = append(errs, fmt.Errorf("%w: initial retry backoff %v above max %v", ErrInvalidOption,
+			p.retryInitialBackoff, p.retryMaxBackoff))
+	}
+	if p.timeout < 0 {
+		errs = append(errs, fmt.Errorf("%w: negative timeout %v", ErrInvalidOption, p.timeout))
+	}
+	for _, c := range p.declaredConflicts() {
+		conflict(c[0], c[1])
+	}
+	if err := p.missingRequiredOptions(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// authModes returns names of explicit auth modes set on p, only one of them can be used
+func (p *OptReqParams) authModes() []string {
+	var modes []string
+	for _, a := range []struct {
+		name string
+		set  bool
+	}{
+		{"WithBasicAuth", p.useBasicAuth},
+		{"WithBearerToken", p.useBearerToken},
+		{"WithDigestAuth", p.useDigestAuth},
+		{"WithOAuth2ClientCredentials", p.tokenSource != nil},
+		{"WithAWSSigV4", p.sigV4 != nil},
+		{"WithJWTClaims", p.jwt != nil},
+		{"WithTokenRefresher", p.tokenRefresher != nil},
+		{"WithAPIKeyInHeader or WithAPIKeyInQuery", p.apiKey != ""},
+	} {
+		if a.set {
+			modes = append(modes, a.name)
+		}
+	}
+	return modes
+}
+
+// ValidateStrict does everything Validate does and also reports combinations which are allowed but most
+// likely not what was meant, like a GET with a body. These are wrapped in ErrMisconfiguration
+func (p *OptReqParams) ValidateStrict() error {
+	errs := []error{p.Validate()}
+	warn := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrMisconfiguration}, args...)...))
+	}
+
+	hasBody := p.body != nil || p.bodyBytes != nil || p.bodyFile != ""
+	switch p.httpMethod {
+	case http.MethodGet, http.MethodHead:
+		if hasBody {
+			warn("%s request with a body", p.httpMethod)
+		}
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		if !hasBody {
+			warn("%s request without a body", p.httpMethod)
+		}
+	}
+	if p.timeout > 0 && p.timeout < defaultDialTimeout {
+		warn("timeout %v shorter than dial timeout %v", p.timeout, defaultDialTimeout)
+	}
+	return errors.Join(errs...)
+}
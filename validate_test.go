@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAuthModesConflict(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts []OptReqParamsOption
+	}{
+		{"basic and bearer", []OptReqParamsOption{WithBasicAuth("u", "p"), WithBearerToken("t")}},
+		{"digest and bearer", []OptReqParamsOption{WithDigestAuth("u", "p"), WithBearerToken("t")}},
+		{"sigv4 and jwt", []OptReqParamsOption{WithAWSSigV4("id", "secret", "us-east-1", "s3"),
+			WithJWTClaims(key, "ES256", map[string]any{"sub": "x"})}},
+		{"oauth2 and basic", []OptReqParamsOption{
+			WithOAuth2ClientCredentials("http://example.com/token", "id", "secret", nil), WithBasicAuth("u", "p")}},
+		{"api key and basic", []OptReqParamsOption{WithAPIKeyInHeader("k", "X-API-Key"), WithBasicAuth("u", "p")}},
+		{"bearer and no auth", []OptReqParamsOption{WithBearerToken("t"), WithNoAuth()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewOptReqParams(tt.opts...).Validate()
+			if !errors.Is(err, ErrConflictingOptions) {
+				t.Errorf("Validate() = %v, want ErrConflictingOptions", err)
+			}
+		})
+	}
+}
+
+func TestValidateSingleAuthMode(t *testing.T) {
+	if err := NewOptReqParams(WithDigestAuth("u", "p")).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidateZeroRetryMultiplierIsUnset(t *testing.T) {
+	p := &OptReqParams{}
+	if err := p.Validate(); err != nil {
+		t.Errorf("Validate() of params not made by NewOptReqParams = %v", err)
+	}
+	p.retryInitialBackoff, p.retryMaxBackoff = 100*time.Millisecond, time.Second
+	if d := p.retryBackoff(2); d != 200*time.Millisecond {
+		t.Errorf("retryBackoff(2) with zero multiplier = %v, want 200ms", d)
+	}
+	if err := NewOptReqParams(WithRetryBackoff(time.Millisecond, time.Second, 0.5)).Validate(); err == nil {
+		t.Error("multiplier 0.5 passed Validate")
+	}
+}
+
+func TestValidateNonHTTPTransport(t *testing.T) {
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) { return nil, errors.New("unused") })
+	err := NewOptReqParams(WithTransport(rt), WithTLSInsecureSkipVerify()).Validate()
+	if !errors.Is(err, ErrInvalidOption) || !strings.Contains(err.Error(), "*http.Transport") {
+		t.Errorf("Validate() = %v, want ErrInvalidOption naming *http.Transport", err)
+	}
+	if err := NewOptReqParams(WithTransport(rt)).Validate(); err != nil {
+		t.Errorf("Validate() without transport options = %v", err)
+	}
+}
+
+func TestMergeOverrideAuthReplacesBase(t *testing.T) {
+	m := Merge(NewOptReqParams(WithBearerToken("t")), NewOptReqParams(WithBasicAuth("u", "p")))
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Validate() of merged params = %v", err)
+	}
+	if m.useBearerToken || !m.useBasicAuth {
+		t.Errorf("merged bearer=%v basic=%v, want only basic", m.useBearerToken, m.useBasicAuth)
+	}
+	m = Merge(NewOptReqParams(WithBearerToken("t")), NewOptReqParams(WithTimeout(time.Second)))
+	if !m.useBearerToken || m.bearerToken != "t" {
+		t.Error("override without auth dropped bearer token of base")
+	}
+}
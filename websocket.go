@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"nhooyr.io/websocket"
+)
+
+// UpgradeWebSocket opens a WebSocket connection to url with the same options CustomHTTPRequest uses, headers,
+// authentication, TLS, proxy and the rest. url is http(s) or ws(s), base url and path params apply to it too.
+// WithTimeout limits only the handshake. Response of the handshake is returned for status and headers
+func UpgradeWebSocket(ctx context.Context, url, email, passwd string,
+	p *OptReqParams) (*websocket.Conn, *http.Response, error) {
+	if err := p.Validate(); err != nil {
+		return nil, nil, err
+	}
+	url, err := resolveURL(url, p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// build handshake request the same way as any other request, only to take its url and headers
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	applyHeaders(req, p)
+	req.Header.Del("Content-Type") // handshake has no body
+	applyRequestID(req, p)
+	applyCookies(req, p)
+	if err := applyAuth(ctx, req, email, passwd, p); err != nil {
+		return nil, nil, err
+	}
+	if p.queryParam != nil {
+		q := req.URL.Query()
+		for k, values := range p.queryParam {
+			for _, v := range values {
+				q.Add(k, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	if req, err = applyRequestMiddleware(req, p); err != nil {
+		return nil, nil, err
+	}
+
+	// websocket uses context for the handshake deadline, client timeout would kill the connection
+	client := newHTTPClient(p)
+	if client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.Timeout)
+		defer cancel()
+		client.Timeout = 0
+	}
+	return websocket.Dial(ctx, req.URL.String(), &websocket.DialOptions{
+		HTTPClient: client,
+		HTTPHeader: req.Header,
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// wsEcho accepts websocket and echoes every message back prefixed with Authorization and X-Client headers of handshake
+func wsEcho(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("Accept: %v", err)
+			return
+		}
+		defer c.CloseNow()
+		prefix := r.Header.Get("Authorization") + "|" + r.Header.Get("X-Client") + "|"
+		for {
+			typ, msg, err := c.Read(r.Context())
+			if err != nil {
+				return
+			}
+			if err := c.Write(r.Context(), typ, append([]byte(prefix), msg...)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestUpgradeWebSocketRoundTrip(t *testing.T) {
+	srv := newCountingServer(t, true, wsEcho(t))
+	p := NewOptReqParams(
+		WithBearerToken("ws-token"),
+		WithHeader("X-Client", "test"),
+		WithTLSConfig(&tls.Config{RootCAs: serverCAs(srv.Server)}),
+		WithTimeout(200*time.Millisecond),
+	)
+	ctx := context.Background()
+	c, res, err := UpgradeWebSocket(ctx, srv.URL, "user@example.com", "passwd", p)
+	if err != nil {
+		t.Fatalf("UpgradeWebSocket: %v", err)
+	}
+	defer c.CloseNow()
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("handshake status %d, want %d", res.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	// connection outlives WithTimeout, it limits only the handshake
+	time.Sleep(300 * time.Millisecond)
+	if err := c.Write(ctx, websocket.MessageText, []byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	typ, msg, err := c.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if want := "Bearer ws-token|test|hello"; typ != websocket.MessageText || string(msg) != want {
+		t.Errorf("got %v %q, want text %q", typ, msg, want)
+	}
+	c.Close(websocket.StatusNormalClosure, "")
+}
+
+func TestUpgradeWebSocketLogin(t *testing.T) {
+	stubLogin(t, "login-token")
+	srv := newCountingServer(t, false, wsEcho(t))
+	ctx := context.Background()
+	c, _, err := UpgradeWebSocket(ctx, srv.URL, "user@example.com", "passwd", NewOptReqParams())
+	if err != nil {
+		t.Fatalf("UpgradeWebSocket: %v", err)
+	}
+	defer c.CloseNow()
+	if err := c.Write(ctx, websocket.MessageText, []byte("hi")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_, msg, err := c.Read(ctx)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if want := "Bearer login-token||hi"; string(msg) != want {
+		t.Errorf("got %q, want %q", msg, want)
+	}
+
+	if _, _, err := UpgradeWebSocket(ctx, srv.URL, "user@example.com", "bad", NewOptReqParams()); err == nil {
+		t.Error("handshake with failed login succeeded")
+	}
+}
+
+func TestUpgradeWebSocketHandshakeTimeout(t *testing.T) {
+	srv := newCountingServer(t, false, slowHandler(time.Second))
+	start := time.Now()
+	_, _, err := UpgradeWebSocket(context.Background(), srv.URL, "", "",
+		NewOptReqParams(WithNoAuth(), WithTimeout(50*time.Millisecond)))
+	if err == nil {
+		t.Fatal("handshake to slow server succeeded")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("handshake gave up after %v, want about 50ms", d)
+	}
+}